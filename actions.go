@@ -0,0 +1,307 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+const defaultActionTimeout = 10 * time.Second
+
+// actionConfig configures an action, exactly one of Exec, FIFO, Webhook and
+// Audit must be set.
+type actionConfig struct {
+	// Exec runs the command with the event as JSON on stdin and as
+	// ETHTOOLSNOOP_* environment variables.
+	Exec []string `yaml:"exec"`
+	// FIFO writes the event as a JSON line to the named pipe, and drops it
+	// if no one is reading the pipe or the pipe is not drained in Timeout.
+	FIFO string `yaml:"fifo"`
+	// Webhook POSTs the event as JSON to the URL, with the Headers.
+	Webhook string            `yaml:"webhook"`
+	Headers map[string]string `yaml:"headers"`
+	// Audit sends the event to the Linux audit subsystem.
+	Audit bool `yaml:"audit"`
+
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// action is fired by the matching rules.
+type action interface {
+	run(ctx context.Context, rule string, ev *tracer.Event) error
+	timeout() time.Duration
+	String() string
+}
+
+func newAction(cfg actionConfig) (action, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultActionTimeout
+	}
+
+	var actions []action
+	if len(cfg.Exec) != 0 {
+		actions = append(actions, &execAction{argv: cfg.Exec, t: timeout})
+	}
+	if cfg.FIFO != "" {
+		actions = append(actions, &fifoAction{path: cfg.FIFO, t: timeout})
+	}
+	if cfg.Webhook != "" {
+		actions = append(actions, &webhookAction{url: cfg.Webhook, headers: cfg.Headers, t: timeout})
+	}
+	if cfg.Audit {
+		actions = append(actions, &auditAction{t: timeout})
+	}
+
+	if len(actions) != 1 {
+		return nil, errors.New("an action must be one of exec, fifo, webhook and audit")
+	}
+
+	return actions[0], nil
+}
+
+// maxRunningActions bounds the actions running in background, beyond which
+// the actions are dropped, so that a burst of events spawns neither
+// unbounded goroutines nor processes.
+const maxRunningActions = 64
+
+var (
+	// running tracks the actions running in background, and actionSlots
+	// bounds them.
+	running     sync.WaitGroup
+	actionSlots = make(chan struct{}, maxRunningActions)
+
+	// droppedActions counts the actions dropped because too many are
+	// running.
+	droppedActions atomic.Uint64
+)
+
+// waitActions waits for the running actions, which are bounded by their
+// timeouts.
+func waitActions() {
+	running.Wait()
+}
+
+// runAction runs the action in background, logging its failure. It drops the
+// action if maxRunningActions are running.
+func runAction(a action, rule string, ev *tracer.Event) {
+	select {
+	case actionSlots <- struct{}{}:
+	default:
+		droppedActions.Add(1)
+		log.Printf("Rule %s: dropped %s, %d actions are running", rule, a, maxRunningActions)
+		return
+	}
+
+	running.Add(1)
+	go func() {
+		defer func() {
+			<-actionSlots
+			running.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), a.timeout())
+		defer cancel()
+
+		if err := a.run(ctx, rule, ev); err != nil {
+			log.Printf("Rule %s: failed to run %s: %s", rule, a, err)
+		}
+	}()
+}
+
+func actionFields(rule string, ev *tracer.Event) []field {
+	return append([]field{{"rule", rule}, {"class", ev.Class().String()}}, eventFields(ev)...)
+}
+
+func actionJSON(rule string, ev *tracer.Event) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := appendJSON(&buf, actionFields(rule, ev)); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+type execAction struct {
+	argv []string
+	t    time.Duration
+}
+
+func (a *execAction) run(ctx context.Context, rule string, ev *tracer.Event) error {
+	data, err := actionJSON(rule, ev)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, a.argv[0], a.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = os.Environ()
+	for _, f := range actionFields(rule, ev) {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("ETHTOOLSNOOP_%s=%v", strings.ToUpper(f.key), f.value))
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+func (a *execAction) timeout() time.Duration { return a.t }
+func (a *execAction) String() string         { return "exec " + a.argv[0] }
+
+type fifoAction struct {
+	path string
+	t    time.Duration
+}
+
+func (a *fifoAction) run(ctx context.Context, rule string, ev *tracer.Event) error {
+	data, err := actionJSON(rule, ev)
+	if err != nil {
+		return err
+	}
+
+	// O_NONBLOCK fails with ENXIO instead of blocking if there is no reader.
+	f, err := os.OpenFile(a.path, os.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The write waits for the reader to drain a full pipe, until the timeout
+	// of the action.
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := f.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	if _, err := f.Write(data); errors.Is(err, os.ErrDeadlineExceeded) {
+		return errors.New("dropped the event as the pipe is not drained in time")
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *fifoAction) timeout() time.Duration { return a.t }
+func (a *fifoAction) String() string         { return "fifo " + a.path }
+
+type webhookAction struct {
+	url     string
+	headers map[string]string
+	t       time.Duration
+}
+
+func (a *webhookAction) run(ctx context.Context, rule string, ev *tracer.Event) error {
+	data, err := actionJSON(rule, ev)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (a *webhookAction) timeout() time.Duration { return a.t }
+func (a *webhookAction) String() string         { return "webhook " + a.url }
+
+// auditTrustedApp is AUDIT_TRUSTED_APP of include/uapi/linux/audit.h, the
+// type of the messages from trusted user space applications.
+const auditTrustedApp = 1121
+
+type auditAction struct {
+	t time.Duration
+}
+
+func (a *auditAction) run(_ context.Context, rule string, ev *tracer.Event) error {
+	var buf bytes.Buffer
+	buf.WriteString("op=ethtool-rule ")
+	appendLogfmt(&buf, actionFields(rule, ev))
+	buf.WriteByte(0)
+
+	return sendAudit(auditTrustedApp, buf.Bytes())
+}
+
+func (a *auditAction) timeout() time.Duration { return a.t }
+func (a *auditAction) String() string         { return "audit" }
+
+// sendAudit sends the message to the kernel audit subsystem, which requires
+// CAP_AUDIT_WRITE.
+func sendAudit(typ uint16, msg []byte) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_AUDIT)
+	if err != nil {
+		return fmt.Errorf("failed to create audit socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	b := make([]byte, unix.NLMSG_HDRLEN+len(msg))
+	binary.NativeEndian.PutUint32(b[0:4], uint32(len(b)))
+	binary.NativeEndian.PutUint16(b[4:6], typ)
+	binary.NativeEndian.PutUint16(b[6:8], unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	binary.NativeEndian.PutUint32(b[8:12], 1)
+	copy(b[unix.NLMSG_HDRLEN:], msg)
+
+	if err := unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("failed to send audit message: %w", err)
+	}
+
+	buf := make([]byte, 4096)
+	n, _, err := unix.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return fmt.Errorf("failed to receive audit ack: %w", err)
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return fmt.Errorf("failed to parse audit ack: %w", err)
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type == unix.NLMSG_ERROR && len(m.Data) >= 4 {
+			if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+				return unix.Errno(-errno)
+			}
+		}
+	}
+
+	return nil
+}
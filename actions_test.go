@@ -0,0 +1,113 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// mkfifo creates a named pipe and opens it for reading without blocking.
+func mkfifo(t *testing.T) (string, *os.File) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "events.fifo")
+	if err := unix.Mkfifo(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := os.OpenFile(path, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+
+	return path, r
+}
+
+func runFIFO(a action) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout())
+	defer cancel()
+
+	return a.run(ctx, "flash", testEvent())
+}
+
+func TestFIFOAction(t *testing.T) {
+	path, r := mkfifo(t)
+
+	a, err := newAction(actionConfig{FIFO: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runFIFO(a); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("failed to parse %q: %s", line, err)
+	}
+	if got["rule"] != "flash" || got["cmd"] != "ETHTOOL_SCHANNELS" || got["class"] != "set" {
+		t.Errorf("unexpected event %s", line)
+	}
+}
+
+// The event is dropped if no one is reading the pipe.
+func TestFIFOActionNoReader(t *testing.T) {
+	path, r := mkfifo(t)
+	r.Close()
+
+	a, err := newAction(actionConfig{FIFO: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runFIFO(a); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("run() = %v, want ENXIO", err)
+	}
+}
+
+// The event is dropped if the pipe is not drained before the timeout.
+func TestFIFOActionTimeout(t *testing.T) {
+	path, _ := mkfifo(t)
+
+	// Fill the pipe, which is never drained.
+	w, err := os.OpenFile(path, os.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.SetWriteDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 1<<20)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("failed to fill the pipe: %v", err)
+	}
+
+	a, err := newAction(actionConfig{FIFO: path, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := runFIFO(a); err == nil {
+		t.Errorf("run() succeeded on a full pipe")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run() took %s, want it to give up at the timeout", elapsed)
+	}
+}
@@ -0,0 +1,108 @@
+/**
+ * Copyright 2024 Leon Hwang.
+ * SPDX-License-Identifier: GPL-2.0
+ */
+
+#ifndef __ETHTOOLSNOOP_ARCH_H_
+#define __ETHTOOLSNOOP_ARCH_H_
+
+/*
+ * vmlinux.h is generated on x86_64, and CO-RE takes care of the kernel
+ * structs on the other architectures. But bpf_tracing.h reads the probed
+ * function's arguments through the arch's register layout, which is missing
+ * from vmlinux.h or clashes with the x86_64 one. Hide the x86_64 layout and
+ * provide the register layout of the target arch here.
+ */
+
+#if defined(__TARGET_ARCH_riscv)
+#define user_regs_struct user_regs_struct___x86
+#elif defined(__TARGET_ARCH_powerpc)
+#define pt_regs pt_regs___x86
+#endif
+
+#include "vmlinux.h"
+
+#undef user_regs_struct
+#undef pt_regs
+
+#if defined(__TARGET_ARCH_arm64)
+
+struct user_pt_regs {
+    __u64 regs[31];
+    __u64 sp;
+    __u64 pc;
+    __u64 pstate;
+};
+
+#elif defined(__TARGET_ARCH_s390)
+
+typedef struct {
+    unsigned long mask;
+    unsigned long addr;
+} __attribute__((aligned(8))) psw_t;
+
+typedef struct {
+    psw_t psw;
+    unsigned long gprs[16];
+    unsigned int acrs[16];
+    unsigned long orig_gpr2;
+} user_pt_regs;
+
+#elif defined(__TARGET_ARCH_riscv)
+
+struct user_regs_struct {
+    unsigned long pc;
+    unsigned long ra;
+    unsigned long sp;
+    unsigned long gp;
+    unsigned long tp;
+    unsigned long t0;
+    unsigned long t1;
+    unsigned long t2;
+    unsigned long s0;
+    unsigned long s1;
+    unsigned long a0;
+    unsigned long a1;
+    unsigned long a2;
+    unsigned long a3;
+    unsigned long a4;
+    unsigned long a5;
+    unsigned long a6;
+    unsigned long a7;
+    unsigned long s2;
+    unsigned long s3;
+    unsigned long s4;
+    unsigned long s5;
+    unsigned long s6;
+    unsigned long s7;
+    unsigned long s8;
+    unsigned long s9;
+    unsigned long s10;
+    unsigned long s11;
+    unsigned long t3;
+    unsigned long t4;
+    unsigned long t5;
+    unsigned long t6;
+};
+
+#elif defined(__TARGET_ARCH_powerpc)
+
+struct pt_regs {
+    unsigned long gpr[32];
+    unsigned long nip;
+    unsigned long msr;
+    unsigned long orig_gpr3;
+    unsigned long ctr;
+    unsigned long link;
+    unsigned long xer;
+    unsigned long ccr;
+    unsigned long softe;
+    unsigned long trap;
+    unsigned long dar;
+    unsigned long dsisr;
+    unsigned long result;
+};
+
+#endif
+
+#endif // __ETHTOOLSNOOP_ARCH_H_
@@ -0,0 +1,326 @@
+/**
+ * Copyright 2024 Leon Hwang.
+ * SPDX-License-Identifier: GPL-2.0
+ */
+
+#include "event.h"
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_compiler.h>
+
+#include "errno.h"
+
+// From include/uapi/linux/sockios.h
+#define SIOCETHTOOL	0x8946
+
+// From include/uapi/linux/netlink.h
+#define NETLINK_GENERIC	16
+#define NLMSG_ALIGN(len) (((len) + 3) & ~3)
+
+#define MAX_NLMSGS 16
+#define MAX_CGROUP_LEVEL 16
+
+// From arch/x86/include/asm/thread_info.h
+#define TS_COMPAT 0x0002
+// From arch/arm64/include/asm/thread_info.h
+#define TIF_32BIT 22
+
+struct exe_key {
+    u64 ino;
+    u32 dev;
+    u32 pad;
+};
+
+/* The commands to deny, keyed by the ETHTOOL_* or ETHTOOL_MSG_* command. */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, u32);
+    __type(value, u8);
+    __uint(max_entries, 256);
+} deny_ioctl_cmds SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, u32);
+    __type(value, u8);
+    __uint(max_entries, 256);
+} deny_genl_cmds SEC(".maps");
+
+/* The executables allowed to issue the denied commands. */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, struct exe_key);
+    __type(value, u8);
+    __uint(max_entries, 1024);
+} allow_exes SEC(".maps");
+
+/* The cgroups whose processes, including the ones in the descendant cgroups,
+ * are allowed to issue the denied commands.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, u64);
+    __type(value, u8);
+    __uint(max_entries, 1024);
+} allow_cgroups SEC(".maps");
+
+static __always_inline bool
+__allowed(void)
+{
+    struct task_struct *task = bpf_get_current_task_btf();
+    struct exe_key key = {};
+    struct file *exe;
+    u64 id;
+
+    exe = BPF_CORE_READ(task, mm, exe_file);
+    if (likely(exe)) {
+        key.ino = BPF_CORE_READ(exe, f_inode, i_ino);
+        key.dev = BPF_CORE_READ(exe, f_inode, i_sb, s_dev);
+        if (bpf_map_lookup_elem(&allow_exes, &key))
+            return true;
+    }
+
+#pragma unroll
+    for (int level = 0; level < MAX_CGROUP_LEVEL; level++) {
+        id = bpf_get_current_ancestor_cgroup_id(level);
+        if (!id)
+            break;
+        if (bpf_map_lookup_elem(&allow_cgroups, &id))
+            return true;
+    }
+
+    return false;
+}
+
+static __always_inline int
+__deny(void *ctx, struct event *ev)
+{
+    fill_task(ev);
+    fill_stack(ctx, ev);
+    ev->ret = -EPERM;
+    ev->verdict = VERDICT_DENIED;
+
+    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, ev, sizeof(*ev));
+
+    return -EPERM;
+}
+
+static __always_inline int
+__file_ioctl(void *ctx, const char *ifname, void *useraddr)
+{
+    struct event ev = {};
+    u32 ethcmd;
+
+    ethcmd = get_ethcmd(useraddr);
+    if (!bpf_map_lookup_elem(&deny_ioctl_cmds, &ethcmd) || __allowed())
+        return 0;
+
+    ev.type = EVENT_TYPE_IOCTL;
+    ev.ethcmd = ethcmd;
+    __builtin_memcpy(ev.ifname, ifname, sizeof(ev.ifname));
+    ev.ifname[IFNAMSIZ - 1] = 0;
+
+    return __deny(ctx, &ev);
+}
+
+/* struct compat_ifreq of the 32-bit processes, of which ifr_data is a 32-bit
+ * pointer.
+ */
+struct compat_ifreq {
+    char ifr_name[IFNAMSIZ];
+    u32 ifr_data;
+};
+
+static __always_inline int
+__file_ioctl_compat(void *ctx, unsigned long arg)
+{
+    struct compat_ifreq ifr;
+
+    if (bpf_probe_read_user(&ifr, sizeof(ifr), (void *) arg))
+        return 0;
+
+    return __file_ioctl(ctx, ifr.ifr_name, (void *) (unsigned long) ifr.ifr_data);
+}
+
+/* __compat_task reports whether the current task is in a 32-bit syscall on a
+ * 64-bit kernel, which is told on x86_64, except for x32, and arm64 only.
+ */
+static __always_inline bool
+__compat_task(void)
+{
+    struct task_struct *task = bpf_get_current_task_btf();
+
+#if defined(__TARGET_ARCH_x86)
+    return BPF_CORE_READ(task, thread_info.status) & TS_COMPAT;
+#elif defined(__TARGET_ARCH_arm64)
+    return BPF_CORE_READ(task, thread_info.flags) & (1UL << TIF_32BIT);
+#else
+    return false;
+#endif
+}
+
+SEC("lsm/file_ioctl")
+int BPF_PROG(lsm_file_ioctl, struct file *file, unsigned int cmd, unsigned long arg, int ret)
+{
+    struct ifreq ifr;
+
+    if (ret || cmd != SIOCETHTOOL)
+        return ret;
+
+    /* The 32-bit processes on the 64-bit kernels before 6.8 pass struct
+     * compat_ifreq here.
+     */
+    if (__compat_task())
+        return __file_ioctl_compat(ctx, arg);
+
+    if (bpf_probe_read_user(&ifr, sizeof(ifr), (void *) arg))
+        return 0;
+
+    return __file_ioctl(ctx, ifr.ifr_ifrn.ifrn_name, ifr.ifr_ifru.ifru_data);
+}
+
+/* The 32-bit processes on the 64-bit kernels go through
+ * security_file_ioctl_compat() since 6.8 instead.
+ */
+SEC("lsm/file_ioctl_compat")
+int BPF_PROG(lsm_file_ioctl_compat, struct file *file, unsigned int cmd, unsigned long arg, int ret)
+{
+    if (ret || cmd != SIOCETHTOOL)
+        return ret;
+
+    return __file_ioctl_compat(ctx, arg);
+}
+
+/* The result of checking a message of a batch. */
+enum {
+    NLMSG_CHECK_NEXT,
+    NLMSG_CHECK_END,
+    NLMSG_CHECK_DENY,
+};
+
+struct nlmsg_walk {
+    unsigned char *data;
+    u32 len;
+    u32 off;
+    int check;
+    u8 genlcmd;
+};
+
+static __always_inline int
+__check_nlmsg(struct nlmsg_walk *w)
+{
+    struct genlmsghdr genlhdr;
+    struct nlmsghdr nlh;
+    u32 genlcmd;
+
+    if (w->off + NLMSG_HDRLEN > w->len)
+        return NLMSG_CHECK_END;
+
+    /* The message which cannot be read may be a denied one. */
+    if (bpf_probe_read_kernel(&nlh, sizeof(nlh), w->data + w->off))
+        return NLMSG_CHECK_DENY;
+
+    /* netlink_rcv_skb() stops at the malformed message, leaving the rest of
+     * the batch unprocessed.
+     */
+    if (nlh.nlmsg_len < NLMSG_HDRLEN || nlh.nlmsg_len > w->len - w->off)
+        return NLMSG_CHECK_END;
+
+    if (nlh.nlmsg_type == ethtool_family_id) {
+        if (bpf_probe_read_kernel(&genlhdr, sizeof(genlhdr), w->data + w->off + NLMSG_HDRLEN))
+            return NLMSG_CHECK_DENY;
+
+        w->genlcmd = genlhdr.cmd;
+        genlcmd = genlhdr.cmd;
+        if (bpf_map_lookup_elem(&deny_genl_cmds, &genlcmd))
+            return NLMSG_CHECK_DENY;
+    }
+
+    w->off += NLMSG_ALIGN(nlh.nlmsg_len);
+
+    return NLMSG_CHECK_NEXT;
+}
+
+static long
+__walk_nlmsg(u32 index, void *ctx)
+{
+    struct nlmsg_walk *w = ctx;
+
+    w->check = __check_nlmsg(w);
+
+    return w->check != NLMSG_CHECK_NEXT;
+}
+
+static __always_inline bool
+__walk_init(struct nlmsg_walk *w, struct sock *sk, struct sk_buff *skb)
+{
+    if (!ethtool_family_id || BPF_CORE_READ(sk, sk_protocol) != NETLINK_GENERIC)
+        return false;
+
+    w->data = BPF_CORE_READ(skb, data);
+    w->len = BPF_CORE_READ(skb, len);
+
+    return true;
+}
+
+/* A single sendmsg() may carry a batch of messages of any families, and the
+ * batch is denied unless it is checked to the end, as the rest may carry the
+ * denied messages.
+ */
+static __always_inline int
+__netlink_send(void *ctx, struct nlmsg_walk *w)
+{
+    struct event ev = {};
+
+    if (w->check == NLMSG_CHECK_END || __allowed())
+        return 0;
+
+    ev.type = EVENT_TYPE_GENL;
+    ev.genlhdr_cmd = w->genlcmd;
+
+    return __deny(ctx, &ev);
+}
+
+SEC("lsm/netlink_send")
+int BPF_PROG(lsm_netlink_send, struct sock *sk, struct sk_buff *skb, int ret)
+{
+    struct nlmsg_walk w = {};
+
+    if (ret || !__walk_init(&w, sk, skb))
+        return ret;
+
+    /* Every message takes NLMSG_HDRLEN bytes at least. If bpf_loop() fails,
+     * w.check is left NLMSG_CHECK_NEXT and the batch is denied.
+     */
+    bpf_loop(w.len / NLMSG_HDRLEN + 1, __walk_nlmsg, &w, 0);
+
+    return __netlink_send(ctx, &w);
+}
+
+/* lsm_netlink_send_legacy replaces lsm_netlink_send on the kernels before
+ * 5.17, which have no bpf_loop(). It checks the first MAX_NLMSGS messages
+ * only, and so denies the longer batches whatever their families.
+ */
+SEC("lsm/netlink_send")
+int BPF_PROG(lsm_netlink_send_legacy, struct sock *sk, struct sk_buff *skb, int ret)
+{
+    struct nlmsg_walk w = {};
+
+    if (ret || !__walk_init(&w, sk, skb))
+        return ret;
+
+#pragma unroll
+    for (int i = 0; i < MAX_NLMSGS; i++) {
+        w.check = __check_nlmsg(&w);
+        if (w.check != NLMSG_CHECK_NEXT)
+            break;
+    }
+    if (w.check == NLMSG_CHECK_NEXT && w.off + NLMSG_HDRLEN > w.len)
+        w.check = NLMSG_CHECK_END;
+
+    return __netlink_send(ctx, &w);
+}
+
+char __license[] SEC("license") = "GPL";
@@ -3,7 +3,7 @@
  * SPDX-License-Identifier: GPL-2.0
  */
 
-#include "vmlinux.h"
+#include "event.h"
 
 #include <bpf/bpf_helpers.h>
 #include <bpf/bpf_tracing.h>
@@ -11,167 +11,634 @@
 #include <bpf/bpf_compiler.h>
 #include <bpf/bpf_map_helpers.h>
 
-#define IFNAMSIZ 16
-
-// From include/uapi/linux/ethtool.h
-#define ETHTOOL_PERQUEUE	0x0000004b /* Set per queue options */
-
-#define EVENT_TYPE_IOCTL 1
-#define EVENT_TYPE_GENL  2
-
-struct event {
-    u8 type;
-    u8 genlhdr_cmd;
-    u16 ethcmd;
-    u32 pid;
-    char ifname[IFNAMSIZ];
-    char comm[TASK_COMM_LEN];
-
+/* The in-flight ethtool command of a task, emitted when the command returns.
+ * The data copied from the command follows the event, and is emitted with it.
+ */
+struct request {
+    struct event ev;
+    u8 data[DATA_LEN];
+    u64 start;
     struct ethnl_req_info *req;
-} __attribute__((packed));
-
-#define SIZEOF_EVENT (offsetof(struct event, req))
+    /* The data in the user memory of ioctl, or in the kernel of genetlink. */
+    void *src;
+};
 
 struct {
-    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
-} events SEC(".maps");
-
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, u64);
+    __type(value, struct request);
+    __uint(max_entries, 4096);
+} requests SEC(".maps");
+
+/* The request is too large for the bpf stack, so it is built here before being
+ * stored in the requests map.
+ */
 struct {
     __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
     __type(key, u32);
-    __type(value, struct event);
+    __type(value, struct request);
     __uint(max_entries, 1);
-} events_cache SEC(".maps");
+} scratch SEC(".maps");
 
-static __always_inline struct event *
-__get_or_init_event(void)
+static __always_inline struct request *
+__new_request(void *ctx, u8 type)
 {
-    struct event *ev, init = {};
-    u32 key = 0;
+    struct request *req;
+    u32 zero = 0;
 
-    ev = bpf_map_lookup_elem(&events_cache, &key);
-    if (likely(ev))
-        return ev;
+    req = bpf_map_lookup_elem(&scratch, &zero);
+    if (unlikely(!req))
+        return NULL;
 
-    bpf_map_update_elem(&events_cache, &key, &init, BPF_NOEXIST);
-    return bpf_map_lookup_elem(&events_cache, &key);
+    /* The stale data is never emitted, as data_len is reset. */
+    __builtin_memset(&req->ev, 0, sizeof(req->ev));
+    req->req = NULL;
+    req->src = NULL;
+
+    req->ev.type = type;
+    fill_task(&req->ev);
+    fill_stack(ctx, &req->ev);
+    req->start = bpf_ktime_get_ns();
+    req->ev.id = req->start;
+
+    return req;
 }
 
-static __always_inline struct event *
-__get_event(void)
+/* The length of the struct the ioctl command passes, which is copied as the
+ * data of the event, or 0 if it is not decoded. It is truncated to DATA_LEN.
+ */
+static __always_inline u32
+get_data_len(u32 ethcmd, void *useraddr)
 {
-    u32 key = 0;
-    return bpf_map_lookup_elem(&events_cache, &key);
+    struct ethtool_rxfh rxfh = {};
+    s8 nwords = 0;
+    u32 size = 0;
+    u64 len;
+
+    switch (ethcmd) {
+    case ETHTOOL_GRXCSUM:
+    case ETHTOOL_SRXCSUM:
+    case ETHTOOL_GTXCSUM:
+    case ETHTOOL_STXCSUM:
+    case ETHTOOL_GSG:
+    case ETHTOOL_SSG:
+    case ETHTOOL_GTSO:
+    case ETHTOOL_STSO:
+    case ETHTOOL_GUFO:
+    case ETHTOOL_SUFO:
+    case ETHTOOL_GGSO:
+    case ETHTOOL_SGSO:
+    case ETHTOOL_GFLAGS:
+    case ETHTOOL_SFLAGS:
+    case ETHTOOL_GGRO:
+    case ETHTOOL_SGRO:
+    case ETHTOOL_PHYS_ID:
+    case ETHTOOL_RESET:
+    case ETHTOOL_SMSGLVL:
+        return sizeof(struct ethtool_value);
+
+    case ETHTOOL_SWOL:
+        return sizeof(struct ethtool_wolinfo);
+
+    case ETHTOOL_SSET:
+        return sizeof(struct ethtool_cmd);
+
+    case ETHTOOL_SLINKSETTINGS:
+        /* The supported, advertising and lp_advertising masks follow. */
+        bpf_probe_read_user(&nwords, sizeof(nwords), useraddr + offsetof(struct ethtool_link_settings, link_mode_masks_nwords));
+        len = sizeof(struct ethtool_link_settings);
+        if (nwords > 0)
+            len += 3 * (u64) nwords * sizeof(u32);
+        break;
+
+    case ETHTOOL_GTUNABLE:
+    case ETHTOOL_STUNABLE:
+    case ETHTOOL_PHY_GTUNABLE:
+    case ETHTOOL_PHY_STUNABLE:
+        bpf_probe_read_user(&size, sizeof(size), useraddr + offsetof(struct ethtool_tunable, len));
+        len = sizeof(struct ethtool_tunable) + (u64) size;
+        break;
+
+    case ETHTOOL_TEST:
+        /* The results are only copied on return. */
+        return sizeof(struct ethtool_test);
+
+    case ETHTOOL_GRXFH:
+    case ETHTOOL_SRXFH:
+    case ETHTOOL_GRXCLSRULE:
+    case ETHTOOL_SRXCLSRLDEL:
+    case ETHTOOL_SRXCLSRLINS:
+        return sizeof(struct ethtool_rxnfc);
+
+    case ETHTOOL_FLASHDEV:
+        return sizeof(struct ethtool_flash);
+
+    case ETHTOOL_GEEPROM:
+    case ETHTOOL_GMODULEEEPROM:
+        /* The bytes read are not copied. */
+        return sizeof(struct ethtool_eeprom);
+
+    case ETHTOOL_SEEPROM:
+        bpf_probe_read_user(&size, sizeof(size), useraddr + offsetof(struct ethtool_eeprom, len));
+        len = sizeof(struct ethtool_eeprom) + (u64) size;
+        break;
+
+    case ETHTOOL_GFEATURES:
+        bpf_probe_read_user(&size, sizeof(size), useraddr + offsetof(struct ethtool_gfeatures, size));
+        len = sizeof(struct ethtool_gfeatures) + (u64) size * sizeof(struct ethtool_get_features_block);
+        break;
+
+    case ETHTOOL_SFEATURES:
+        bpf_probe_read_user(&size, sizeof(size), useraddr + offsetof(struct ethtool_sfeatures, size));
+        len = sizeof(struct ethtool_sfeatures) + (u64) size * sizeof(struct ethtool_set_features_block);
+        break;
+
+    case ETHTOOL_GRXFHINDIR:
+    case ETHTOOL_SRXFHINDIR:
+        /* struct ethtool_rxfh_indir is {cmd, size, ring_index[size]}, which
+         * is not in vmlinux.h.
+         */
+        bpf_probe_read_user(&size, sizeof(size), useraddr + sizeof(u32));
+        len = 2 * sizeof(u32) + (u64) size * sizeof(u32);
+        break;
+
+    case ETHTOOL_GRSSH:
+    case ETHTOOL_SRSSH:
+        bpf_probe_read_user(&rxfh, sizeof(rxfh), useraddr);
+        if (rxfh.indir_size == ETH_RXFH_INDIR_NO_CHANGE)
+            rxfh.indir_size = 0;
+        len = sizeof(rxfh) + (u64) rxfh.indir_size * sizeof(u32) + rxfh.key_size;
+        break;
+
+    default:
+        return 0;
+    }
+
+    return len > DATA_LEN ? DATA_LEN : len;
 }
 
-static __always_inline struct event *
-__get_and_del_event(void)
+/* The length of the attributes of the genetlink message, which are copied as
+ * the data of the event. Only the request header, the first attribute, is
+ * copied if the message is not decoded.
+ */
+static __always_inline u32
+get_genl_data_len(u8 cmd, struct nlmsghdr *nlh)
 {
-    struct event *ev;
-    u32 key = 0;
+    bool header_only = false;
+    struct nlattr nla;
+    u32 len;
+
+    switch (cmd) {
+    case ETHTOOL_MSG_LINKINFO_SET:
+    case ETHTOOL_MSG_LINKMODES_SET:
+    case ETHTOOL_MSG_DEBUG_SET:
+    case ETHTOOL_MSG_WOL_SET:
+    case ETHTOOL_MSG_FEATURES_SET:
+    case ETHTOOL_MSG_MODULE_EEPROM_GET:
+    case ETHTOOL_MSG_MODULE_FW_FLASH_ACT:
+    case ETHTOOL_MSG_RSS_SET:
+    case ETHTOOL_MSG_RSS_CREATE_ACT:
+    case ETHTOOL_MSG_RSS_DELETE_ACT:
+        break;
+    default:
+        header_only = true;
+    }
+
+    len = BPF_CORE_READ(nlh, nlmsg_len);
+    if (len <= NLMSG_HDRLEN + GENL_HDRLEN)
+        return 0;
+
+    len -= NLMSG_HDRLEN + GENL_HDRLEN;
+
+    if (header_only) {
+        if (bpf_probe_read_kernel(&nla, sizeof(nla), (void *) nlh + NLMSG_HDRLEN + GENL_HDRLEN) ||
+            (nla.nla_type & NLA_TYPE_MASK) != ETHTOOL_A_REQUEST_HEADER)
+            return 0;
+        if (nla.nla_len < len)
+            len = nla.nla_len;
+    }
+
+    return len > DATA_LEN ? DATA_LEN : len;
+}
 
-    ev = bpf_map_lookup_elem(&events_cache, &key);
-    if (unlikely(!ev))
-        return NULL;
+static __always_inline long
+__read_data(struct request *req)
+{
+    u32 len = req->ev.data_len;
+
+    long err;
+
+    if (!len || !req->src)
+        return 0;
+    if (len > DATA_LEN)
+        len = DATA_LEN;
+
+    if (req->ev.type == EVENT_TYPE_IOCTL)
+        err = bpf_probe_read_user(req->data, len, req->src);
+    else
+        err = bpf_probe_read_kernel(req->data, len, req->src);
+    if (err)
+        req->ev.data_len = 0;
 
-    bpf_map_delete_elem(&events_cache, &key);
-    return ev;
+    return err;
 }
 
-static __always_inline u32
-get_ethcmd(void *useraddr)
+/* Emit the event followed by its data. */
+static __always_inline void
+__output_data(void *ctx, struct request *req)
+{
+    u32 size = req->ev.data_len;
+
+    if (size > DATA_LEN)
+        size = DATA_LEN;
+
+    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, req, sizeof(req->ev) + size);
+}
+
+/* Read the data again for what the kernel writes back, e.g. the rule of
+ * ETHTOOL_GRXCLSRULE. The results of ETHTOOL_TEST follow struct
+ * ethtool_test, and ETHTOOL_RESET writes back the components not reset,
+ * following which the requested ones are kept.
+ *
+ * The data is read into the scratch request, which is free on return, and the
+ * request is returned as is with the entry copy if the reading fails.
+ */
+static __always_inline struct request *
+__read_ret_data(struct request *req)
 {
-    u32 cmd;
+    const u32 val_size = sizeof(struct ethtool_value);
+    u32 ethcmd = req->ev.ethcmd;
+    struct request *ret_req;
+    u32 zero = 0, len = 0;
+    u64 size;
+
+    ret_req = bpf_map_lookup_elem(&scratch, &zero);
+    if (unlikely(!ret_req))
+        return req;
+
+    __builtin_memcpy(&ret_req->ev, &req->ev, sizeof(req->ev));
+    ret_req->src = req->src;
+
+    if (ethcmd == ETHTOOL_TEST) {
+        bpf_probe_read_user(&len, sizeof(len), req->src + offsetof(struct ethtool_test, len));
+        size = sizeof(struct ethtool_test) + (u64) len * sizeof(u64);
+        ret_req->ev.data_len = size > DATA_LEN ? DATA_LEN : size;
+    }
+
+    if (ethcmd == ETHTOOL_RESET && req->ev.data_len == val_size)
+        __builtin_memcpy(ret_req->data + val_size, req->data, val_size);
 
-    bpf_probe_read_user(&cmd, sizeof(cmd), useraddr);
-    if (cmd == ETHTOOL_PERQUEUE)
-        cmd = bpf_probe_read_user(&cmd, sizeof(cmd), useraddr + sizeof(cmd));
+    if (__read_data(ret_req))
+        return req;
 
-    return cmd;
+    if (ethcmd == ETHTOOL_RESET && req->ev.data_len == val_size)
+        ret_req->ev.data_len = 2 * val_size;
+
+    return ret_req;
+}
+
+static __always_inline struct request *
+__get_request(void)
+{
+    u64 tid = bpf_get_current_pid_tgid();
+    return bpf_map_lookup_elem(&requests, &tid);
 }
 
 static __always_inline int
-__kp_dev_ethtool(void *ctx, struct net *net, struct ifreq *ifr, void *useraddr)
+__output_request(void *ctx, int ret, u8 type)
 {
-    struct event ev = {};
+    u64 tid = bpf_get_current_pid_tgid();
+    struct request *req;
 
-    ev.type = EVENT_TYPE_IOCTL;
-    ev.ethcmd = get_ethcmd(useraddr);
+    req = bpf_map_lookup_elem(&requests, &tid);
+    if (unlikely(!req) || req->ev.type != type)
+        return BPF_OK;
 
-    ev.pid = bpf_get_current_pid_tgid() >> 32;
+    req->ev.ret = ret;
+    req->ev.duration = bpf_ktime_get_ns() - req->start;
 
-    bpf_probe_read_kernel_str(ev.ifname, sizeof(ev.ifname), ifr->ifr_ifrn.ifrn_name);
-    bpf_get_current_comm(ev.comm, sizeof(ev.comm));
+    if (!ret && type == EVENT_TYPE_IOCTL)
+        req = __read_ret_data(req);
 
-    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &ev, sizeof(ev));
+    __output_data(ctx, req);
+    bpf_map_delete_elem(&requests, &tid);
 
     return BPF_OK;
 }
 
 SEC("kprobe/dev_ethtool")
-int kp_dev_ethtool(struct pt_regs *ctx)
+int BPF_KPROBE(kp_dev_ethtool, struct net *net, struct ifreq *ifr, void *useraddr)
 {
-    struct net *net = (typeof(net))(void *)(u64) PT_REGS_PARM1(ctx);
-    struct ifreq *ifr = (typeof(ifr))(void *)(u64) PT_REGS_PARM2(ctx);
-    void *useraddr = (typeof(useraddr))(void *)(u64) PT_REGS_PARM3(ctx);
-    return __kp_dev_ethtool(ctx, net, ifr, useraddr);
+    u64 tid = bpf_get_current_pid_tgid();
+    struct request *req;
+
+    req = __new_request(ctx, EVENT_TYPE_IOCTL);
+    if (unlikely(!req))
+        return BPF_OK;
+
+    req->ev.ethcmd = get_ethcmd(useraddr);
+    bpf_probe_read_kernel_str(req->ev.ifname, sizeof(req->ev.ifname), ifr->ifr_ifrn.ifrn_name);
+
+    /* None of the decoded commands is a sub-command of ETHTOOL_PERQUEUE, so
+     * useraddr points to their structs.
+     */
+    req->src = useraddr;
+    req->ev.data_len = get_data_len(req->ev.ethcmd, useraddr);
+    __read_data(req);
+
+    bpf_map_update_elem(&requests, &tid, req, BPF_ANY);
+
+    return BPF_OK;
 }
 
-static __always_inline void
-__get_dev_name(struct event *ev, struct ethnl_req_info *req)
+SEC("kretprobe/dev_ethtool")
+int BPF_KRETPROBE(krp_dev_ethtool, int ret)
 {
-    struct net_device *dev = BPF_CORE_READ(req, dev);
+    return __output_request(ctx, ret, EVENT_TYPE_IOCTL);
+}
 
-    if (likely(dev))
-        bpf_probe_read_kernel_str(ev->ifname, sizeof(ev->ifname), dev->name);
+/* dev_ethtool() looks up the netdev by the name in struct ifreq. */
+SEC("kretprobe/__dev_get_by_name")
+int BPF_KRETPROBE(krp_dev_get_by_name, struct net_device *dev)
+{
+    struct request *req = __get_request();
+
+    if (likely(!req) || req->ev.type != EVENT_TYPE_IOCTL || req->ev.ifindex || !dev)
+        return BPF_OK;
+
+    fill_dev(&req->ev, dev);
+
+    return BPF_OK;
 }
 
-SEC("kprobe/ethnl_default_doit")
-int kp_ethnl_doit(struct pt_regs *ctx)
+SEC("kprobe/genl_rcv_msg")
+int BPF_KPROBE(kp_genl_rcv_msg, struct sk_buff *skb, struct nlmsghdr *nlh)
 {
-    struct genl_info *info = (typeof(info))(void *)(u64) PT_REGS_PARM2(ctx);
-    u8 cmd = BPF_CORE_READ(info, genlhdr, cmd);
-    struct event *ev = __get_or_init_event();
+    struct genlmsghdr *genlhdr = (void *) nlh + NLMSG_HDRLEN;
+    u64 tid = bpf_get_current_pid_tgid();
+    struct request *req;
+
+    if (!ethtool_family_id || BPF_CORE_READ(nlh, nlmsg_type) != ethtool_family_id)
+        return BPF_OK;
 
-    if (unlikely(!ev))
+    req = __new_request(ctx, EVENT_TYPE_GENL);
+    if (unlikely(!req))
         return BPF_OK;
 
-    ev->type = EVENT_TYPE_GENL;
-    ev->genlhdr_cmd = cmd;
-    ev->req = NULL;
+    req->ev.genlhdr_cmd = BPF_CORE_READ(genlhdr, cmd);
+
+    req->src = (void *) genlhdr + GENL_HDRLEN;
+    req->ev.data_len = get_genl_data_len(req->ev.genlhdr_cmd, nlh);
+    __read_data(req);
+
+    bpf_map_update_elem(&requests, &tid, req, BPF_ANY);
 
     return BPF_OK;
 }
 
+SEC("kretprobe/genl_rcv_msg")
+int BPF_KRETPROBE(krp_genl_rcv_msg, int ret)
+{
+    return __output_request(ctx, ret, EVENT_TYPE_GENL);
+}
+
 SEC("kprobe/ethnl_parse_header_dev_get")
-int kp_ethnl_dev(struct pt_regs *ctx)
+int BPF_KPROBE(kp_ethnl_dev, struct ethnl_req_info *req_info)
 {
-    struct ethnl_req_info *req = (typeof(req))(void *)(u64) PT_REGS_PARM1(ctx);
-    struct event *ev = __get_event();
+    struct request *req = __get_request();
+
+    if (likely(req))
+        req->req = req_info;
 
-    if (unlikely(!ev))
+    return BPF_OK;
+}
+
+SEC("kretprobe/ethnl_parse_header_dev_get")
+int BPF_KRETPROBE(krp_ethnl_dev, int ret)
+{
+    struct request *req = __get_request();
+
+    struct net_device *dev;
+
+    if (unlikely(!req || !req->req) || ret)
         return BPF_OK;
 
-    ev->req = req;
+    dev = BPF_CORE_READ(req->req, dev);
+    if (likely(dev))
+        fill_dev(&req->ev, dev);
 
     return BPF_OK;
 }
 
-SEC("kretprobe/ethnl_parse_header_dev_get")
-int krp_ethnl_dev(struct pt_regs *ctx)
+/* The in-kernel consumers, e.g. bonding, team and bridge, call the ethtool
+ * functions directly. They are traced only outside of the traced ioctl and
+ * genetlink requests, and attributed to the caller on the kernel stack.
+ */
+static __always_inline int
+__kp_ethtool_kernel(struct pt_regs *ctx, struct net_device *dev, u16 ethcmd)
 {
-    struct event *ev = __get_and_del_event();
+    u64 tid = bpf_get_current_pid_tgid();
+    struct request *req;
+    u64 stack[2];
 
-    if (unlikely(!ev))
+    if (__get_request())
         return BPF_OK;
 
-    if (likely(ev->req))
-        __get_dev_name(ev, ev->req);
+    req = __new_request(ctx, EVENT_TYPE_KERNEL);
+    if (unlikely(!req))
+        return BPF_OK;
+
+    req->ev.ethcmd = ethcmd;
+    if (likely(dev))
+        fill_dev(&req->ev, dev);
+
+    /* stack[0] is the probed function itself. */
+    if (bpf_get_stack(ctx, stack, sizeof(stack), 0) == sizeof(stack))
+        req->ev.caller = stack[1];
+
+    bpf_map_update_elem(&requests, &tid, req, BPF_NOEXIST);
+
+    return BPF_OK;
+}
 
-    ev->pid = bpf_get_current_pid_tgid() >> 32;
-    bpf_get_current_comm(ev->comm, sizeof(ev->comm));
+SEC("kprobe/__ethtool_get_link_ksettings")
+int BPF_KPROBE(kp_get_link_ksettings, struct net_device *dev)
+{
+    return __kp_ethtool_kernel(ctx, dev, ETHTOOL_GLINKSETTINGS);
+}
+
+SEC("kretprobe/__ethtool_get_link_ksettings")
+int BPF_KRETPROBE(krp_get_link_ksettings, int ret)
+{
+    return __output_request(ctx, ret, EVENT_TYPE_KERNEL);
+}
+
+SEC("kprobe/__ethtool_get_ts_info")
+int BPF_KPROBE(kp_get_ts_info, struct net_device *dev)
+{
+    return __kp_ethtool_kernel(ctx, dev, ETHTOOL_GET_TS_INFO);
+}
+
+SEC("kretprobe/__ethtool_get_ts_info")
+int BPF_KRETPROBE(krp_get_ts_info, int ret)
+{
+    return __output_request(ctx, ret, EVENT_TYPE_KERNEL);
+}
+
+/* The ethtool_ops callbacks of a driver, attached with the index of the
+ * callback as the cookie.
+ */
+struct op_key {
+    u64 tid;
+    u64 cookie;
+};
+
+struct op_call {
+    u64 start;
+    struct net_device *dev;
+    s32 kstack;
+    s32 ustack;
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, struct op_key);
+    __type(value, struct op_call);
+    __uint(max_entries, 4096);
+} op_calls SEC(".maps");
+
+SEC("kprobe/ethtool_op")
+int BPF_KPROBE(kp_ethtool_op, struct net_device *dev)
+{
+    struct op_key key = {
+        .tid = bpf_get_current_pid_tgid(),
+        .cookie = bpf_get_attach_cookie(ctx),
+    };
+    struct op_call call = {
+        .start = bpf_ktime_get_ns(),
+        .dev = dev,
+        .kstack = get_stack(ctx, STACK_KERNEL, 0),
+        .ustack = get_stack(ctx, STACK_USER, BPF_F_USER_STACK),
+    };
+
+    bpf_map_update_elem(&op_calls, &key, &call, BPF_ANY);
+
+    return BPF_OK;
+}
+
+SEC("kretprobe/ethtool_op")
+int BPF_KRETPROBE(krp_ethtool_op, long ret)
+{
+    struct op_key key = {
+        .tid = bpf_get_current_pid_tgid(),
+        .cookie = bpf_get_attach_cookie(ctx),
+    };
+    struct request *req;
+    struct op_call *call;
+    struct event ev = {};
+
+    call = bpf_map_lookup_elem(&op_calls, &key);
+    if (unlikely(!call))
+        return BPF_OK;
+
+    /* Nest it under the traced request of the task, if any. */
+    req = __get_request();
+    if (req) {
+        ev = req->ev;
+        ev.parent = req->ev.id;
+        ev.data_len = 0;
+    } else {
+        fill_task(&ev);
+        if (likely(call->dev))
+            fill_dev(&ev, call->dev);
+    }
+
+    ev.type = EVENT_TYPE_OP;
+    ev.caller = 0;
+    ev.id = call->start;
+    ev.op = key.cookie;
+    ev.kstack = call->kstack;
+    ev.ustack = call->ustack;
+    ev.ret = ret;
+    ev.duration = bpf_ktime_get_ns() - call->start;
+
+    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &ev, sizeof(ev));
+    bpf_map_delete_elem(&op_calls, &key);
+
+    return BPF_OK;
+}
+
+static __always_inline void
+__output_notify(void *ctx, struct sk_buff *skb, struct net_device *dev, u8 cmd)
+{
+    struct request *ntf, *req;
+    unsigned char *genlhdr;
+    u32 len;
+
+    ntf = __new_request(ctx, EVENT_TYPE_NOTIFY);
+    if (unlikely(!ntf))
+        return;
+
+    ntf->ev.genlhdr_cmd = cmd;
+    if (dev)
+        fill_dev(&ntf->ev, dev);
+
+    req = __get_request();
+    if (req)
+        ntf->ev.parent = req->ev.id;
+
+    /* The progress of the module firmware flash is only in the
+     * notifications.
+     */
+    genlhdr = (void *) BPF_CORE_READ(skb, data) + NLMSG_HDRLEN;
+    len = BPF_CORE_READ(skb, len);
+    if (cmd == ETHTOOL_MSG_MODULE_FW_FLASH_NTF && len > NLMSG_HDRLEN + GENL_HDRLEN) {
+        len -= NLMSG_HDRLEN + GENL_HDRLEN;
+        ntf->src = genlhdr + GENL_HDRLEN;
+        ntf->ev.data_len = len > DATA_LEN ? DATA_LEN : len;
+        __read_data(ntf);
+    }
+
+    __output_data(ctx, ntf);
+}
+
+/* Every ethtool genetlink notification, including the ones initiated by the
+ * drivers, is multicast by ethnl_multicast(). The notifications caused by a
+ * traced request of the task refer to it as the parent.
+ */
+SEC("kprobe/ethnl_multicast")
+int BPF_KPROBE(kp_ethnl_multicast, struct sk_buff *skb, struct net_device *dev)
+{
+    struct genlmsghdr *genlhdr;
+
+    genlhdr = (void *) BPF_CORE_READ(skb, data) + NLMSG_HDRLEN;
+    __output_notify(ctx, skb, dev, BPF_CORE_READ(genlhdr, cmd));
+
+    return BPF_OK;
+}
+
+/* The notifications of the module firmware flash are unicast to the socket
+ * requesting the flash by genlmsg_unicast(), which is inlined into
+ * netlink_unicast(), from the work flashing the firmware. The device is
+ * identified by the request header of the notification.
+ */
+SEC("kprobe/netlink_unicast")
+int BPF_KPROBE(kp_netlink_unicast, struct sock *ssk, struct sk_buff *skb)
+{
+    struct genlmsghdr *genlhdr;
+    struct nlmsghdr *nlh;
+    u8 cmd;
+
+    nlh = (void *) BPF_CORE_READ(skb, data);
+    if (!ethtool_family_id || BPF_CORE_READ(nlh, nlmsg_type) != ethtool_family_id)
+        return BPF_OK;
+
+    genlhdr = (void *) nlh + NLMSG_HDRLEN;
+    cmd = BPF_CORE_READ(genlhdr, cmd);
+    if (cmd != ETHTOOL_MSG_MODULE_FW_FLASH_NTF)
+        return BPF_OK;
 
-    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, ev, SIZEOF_EVENT);
+    __output_notify(ctx, skb, NULL, cmd);
 
     return BPF_OK;
 }
@@ -0,0 +1,262 @@
+/**
+ * Copyright 2024 Leon Hwang.
+ * SPDX-License-Identifier: GPL-2.0
+ */
+
+#ifndef __ETHTOOLSNOOP_EVENT_H_
+#define __ETHTOOLSNOOP_EVENT_H_
+
+#include "arch.h"
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+
+#define IFNAMSIZ 16
+#define TTY_NAME_LEN 16
+#define DRIVER_NAME_LEN 32
+#define BUS_INFO_LEN 32
+#define NLMSG_HDRLEN 16
+#define MAX_STACK_DEPTH 127
+#define GENL_HDRLEN 4
+#define DATA_LEN 2048
+#define NLA_TYPE_MASK 0x3fff /* ~(NLA_F_NESTED | NLA_F_NET_BYTEORDER) */
+
+// From include/uapi/linux/ethtool.h
+#define ETHTOOL_SSET		0x00000002 /* DEPRECATED, Set settings. */
+#define ETHTOOL_SWOL		0x00000006 /* Set wake-on-lan options. */
+#define ETHTOOL_SMSGLVL		0x00000008 /* Set driver msg level. */
+#define ETHTOOL_GEEPROM		0x0000000b /* Get EEPROM data */
+#define ETHTOOL_SEEPROM		0x0000000c /* Set EEPROM data. */
+#define ETHTOOL_GRXCSUM		0x00000014 /* Get RX hw csum enable (ethtool_value) */
+#define ETHTOOL_SRXCSUM		0x00000015 /* Set RX hw csum enable (ethtool_value) */
+#define ETHTOOL_GTXCSUM		0x00000016 /* Get TX hw csum enable (ethtool_value) */
+#define ETHTOOL_STXCSUM		0x00000017 /* Set TX hw csum enable (ethtool_value) */
+#define ETHTOOL_GSG		0x00000018 /* Get scatter-gather enable */
+#define ETHTOOL_SSG		0x00000019 /* Set scatter-gather enable */
+#define ETHTOOL_TEST		0x0000001a /* execute NIC self-test. */
+#define ETHTOOL_PHYS_ID		0x0000001c /* identify the NIC */
+#define ETHTOOL_GTSO		0x0000001e /* Get TSO enable (ethtool_value) */
+#define ETHTOOL_STSO		0x0000001f /* Set TSO enable (ethtool_value) */
+#define ETHTOOL_GUFO		0x00000021 /* Get UFO enable (ethtool_value) */
+#define ETHTOOL_SUFO		0x00000022 /* Set UFO enable (ethtool_value) */
+#define ETHTOOL_GGSO		0x00000023 /* Get GSO enable (ethtool_value) */
+#define ETHTOOL_SGSO		0x00000024 /* Set GSO enable (ethtool_value) */
+#define ETHTOOL_GFLAGS		0x00000025 /* Get flags bitmap(ethtool_value) */
+#define ETHTOOL_SFLAGS		0x00000026 /* Set flags bitmap(ethtool_value) */
+#define ETHTOOL_GRXFH		0x00000029 /* Get RX flow hash configuration */
+#define ETHTOOL_SRXFH		0x0000002a /* Set RX flow hash configuration */
+#define ETHTOOL_GGRO		0x0000002b /* Get GRO enable (ethtool_value) */
+#define ETHTOOL_SGRO		0x0000002c /* Set GRO enable (ethtool_value) */
+#define ETHTOOL_GRXCLSRULE	0x0000002f /* Get RX classification rule */
+#define ETHTOOL_SRXCLSRLDEL	0x00000031 /* Delete RX classification rule */
+#define ETHTOOL_SRXCLSRLINS	0x00000032 /* Insert RX classification rule */
+#define ETHTOOL_FLASHDEV	0x00000033 /* Flash firmware to device */
+#define ETHTOOL_RESET		0x00000034 /* Reset hardware */
+#define ETHTOOL_GRXFHINDIR	0x00000038 /* Get RX flow hash indir'n table */
+#define ETHTOOL_SRXFHINDIR	0x00000039 /* Set RX flow hash indir'n table */
+#define ETHTOOL_GFEATURES	0x0000003a /* Get device offload settings */
+#define ETHTOOL_SFEATURES	0x0000003b /* Change device offload settings */
+#define ETHTOOL_GET_TS_INFO	0x00000041 /* Get time stamping and PHC info */
+#define ETHTOOL_GMODULEEEPROM	0x00000043 /* Get plug-in module eeprom */
+#define ETHTOOL_GRSSH		0x00000046 /* Get RX flow hash configuration */
+#define ETHTOOL_SRSSH		0x00000047 /* Set RX flow hash configuration */
+#define ETHTOOL_GTUNABLE	0x00000048 /* Get tunable configuration */
+#define ETHTOOL_STUNABLE	0x00000049 /* Set tunable configuration */
+#define ETHTOOL_PERQUEUE	0x0000004b /* Set per queue options */
+#define ETHTOOL_GLINKSETTINGS	0x0000004c /* Get ethtool_link_settings */
+#define ETHTOOL_SLINKSETTINGS	0x0000004d /* Set ethtool_link_settings */
+#define ETHTOOL_PHY_GTUNABLE	0x0000004e /* Get PHY tunable configuration */
+#define ETHTOOL_PHY_STUNABLE	0x0000004f /* Set PHY tunable configuration */
+
+#define ETH_RXFH_INDIR_NO_CHANGE 0xffffffff
+
+#define ETHTOOL_A_REQUEST_HEADER	1 /* ETHTOOL_A_*_HEADER of all the messages */
+
+#define ETHTOOL_MSG_LINKINFO_SET	3
+#define ETHTOOL_MSG_LINKMODES_SET	5
+#define ETHTOOL_MSG_DEBUG_SET		8
+#define ETHTOOL_MSG_WOL_SET		10
+#define ETHTOOL_MSG_FEATURES_SET	12
+#define ETHTOOL_MSG_MODULE_EEPROM_GET	31
+#define ETHTOOL_MSG_MODULE_FW_FLASH_ACT	44
+#define ETHTOOL_MSG_RSS_SET		48
+#define ETHTOOL_MSG_RSS_CREATE_ACT	49
+#define ETHTOOL_MSG_RSS_DELETE_ACT	50
+
+#define ETHTOOL_MSG_MODULE_FW_FLASH_NTF	44
+
+#define EVENT_TYPE_IOCTL 1
+#define EVENT_TYPE_GENL  2
+#define EVENT_TYPE_KERNEL 3
+#define EVENT_TYPE_OP     4
+#define EVENT_TYPE_NOTIFY 5
+
+#define VERDICT_ALLOWED 0
+#define VERDICT_DENIED  1
+
+#define STACK_KERNEL 1
+#define STACK_USER   2
+
+struct event {
+    u8 type;
+    u8 genlhdr_cmd;
+    u16 ethcmd;
+    u32 pid;
+    char ifname[IFNAMSIZ];
+    char comm[TASK_COMM_LEN];
+    s32 ret;
+    u64 duration;
+    u8 verdict;
+    u32 uid;
+    u32 gid;
+    u32 loginuid;
+    u32 sessionid;
+    char tty[TTY_NAME_LEN];
+    u32 ifindex;
+    char driver[DRIVER_NAME_LEN];
+    char bus[BUS_INFO_LEN];
+    char master[IFNAMSIZ];
+    u64 caller;
+    u64 id;
+    u64 parent;
+    u32 op;
+    s32 kstack;
+    s32 ustack;
+    u16 data_len;
+} __attribute__((packed));
+
+/* The genetlink family id of ethtool, rewritten before loading. */
+volatile const u16 ethtool_family_id = 0;
+
+/* The STACK_* stacks to capture, rewritten before loading. */
+volatile const u8 stack_mode = 0;
+
+/* Shared by all the objects by replacing the map when loading. */
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} events SEC(".maps");
+
+/* The captured stacks, shared like events. It is shrunk to a single entry if
+ * no stack is captured.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_STACK_TRACE);
+    __type(key, u32);
+    __type(value, u64[MAX_STACK_DEPTH]);
+    __uint(max_entries, 4096);
+} stacks SEC(".maps");
+
+/* Fill the identity of the current task: pid, comm, uid/gid, the audit
+ * loginuid/sessionid and the controlling tty.
+ */
+static __always_inline void
+fill_task(struct event *ev)
+{
+    struct task_struct *task = (void *) bpf_get_current_task();
+    u64 uid_gid = bpf_get_current_uid_gid();
+    struct tty_struct *tty;
+
+    ev->pid = bpf_get_current_pid_tgid() >> 32;
+    bpf_get_current_comm(ev->comm, sizeof(ev->comm));
+
+    ev->uid = (u32) uid_gid;
+    ev->gid = uid_gid >> 32;
+
+    /* loginuid and sessionid exist only with CONFIG_AUDIT. */
+    ev->loginuid = (u32) -1;
+    ev->sessionid = (u32) -1;
+    if (bpf_core_field_exists(task->loginuid)) {
+        ev->loginuid = BPF_CORE_READ(task, loginuid.val);
+        ev->sessionid = BPF_CORE_READ(task, sessionid);
+    }
+
+    tty = BPF_CORE_READ(task, signal, tty);
+    if (tty)
+        bpf_probe_read_kernel_str(ev->tty, sizeof(ev->tty), tty->name);
+}
+
+/* Capture the stack if it is in stack_mode, or return -1. The same stacks
+ * share an id, which is never deleted, and a bucket taken by another stack is
+ * reused instead of failing with -EEXIST.
+ */
+static __always_inline s32
+get_stack(void *ctx, u8 mode, u64 flags)
+{
+    if (!(stack_mode & mode))
+        return -1;
+
+    return bpf_get_stackid(ctx, &stacks, flags | BPF_F_REUSE_STACKID);
+}
+
+static __always_inline void
+fill_stack(void *ctx, struct event *ev)
+{
+    ev->kstack = get_stack(ctx, STACK_KERNEL, 0);
+    ev->ustack = get_stack(ctx, STACK_USER, BPF_F_USER_STACK);
+}
+
+/* Fill the name of the master device, e.g. the bond, which is the first upper
+ * device if it is the master, like netdev_master_upper_dev_get().
+ */
+static __always_inline void
+fill_master(struct event *ev, struct net_device *dev)
+{
+    struct list_head *upper = &dev->adj_list.upper;
+    struct netdev_adjacent *adj;
+    struct list_head *first;
+    struct net_device *master;
+
+    first = BPF_CORE_READ(dev, adj_list.upper.next);
+    if (!first || first == upper)
+        return;
+
+    adj = (void *) first - bpf_core_field_offset(struct netdev_adjacent, list);
+    if (!BPF_CORE_READ(adj, master))
+        return;
+
+    master = BPF_CORE_READ(adj, dev);
+    if (master)
+        bpf_probe_read_kernel_str(ev->master, sizeof(ev->master), master->name);
+}
+
+/* Fill the netdev: ifindex, name, the master device, the driver and the bus
+ * address of its parent device, or the rtnl link kind for the virtual devices.
+ */
+static __always_inline void
+fill_dev(struct event *ev, struct net_device *dev)
+{
+    struct device *parent = BPF_CORE_READ(dev, dev.parent);
+    const char *name;
+
+    ev->ifindex = BPF_CORE_READ(dev, ifindex);
+    bpf_probe_read_kernel_str(ev->ifname, sizeof(ev->ifname), dev->name);
+    fill_master(ev, dev);
+
+    if (parent) {
+        name = BPF_CORE_READ(parent, driver, name);
+        if (name)
+            bpf_probe_read_kernel_str(ev->driver, sizeof(ev->driver), name);
+
+        name = BPF_CORE_READ(parent, kobj.name);
+        if (name)
+            bpf_probe_read_kernel_str(ev->bus, sizeof(ev->bus), name);
+    } else {
+        name = BPF_CORE_READ(dev, rtnl_link_ops, kind);
+        if (name)
+            bpf_probe_read_kernel_str(ev->driver, sizeof(ev->driver), name);
+    }
+}
+
+static __always_inline u32
+get_ethcmd(void *useraddr)
+{
+    u32 cmd;
+
+    bpf_probe_read_user(&cmd, sizeof(cmd), useraddr);
+    if (cmd == ETHTOOL_PERQUEUE)
+        bpf_probe_read_user(&cmd, sizeof(cmd), useraddr + sizeof(cmd));
+
+    return cmd;
+}
+
+#endif // __ETHTOOLSNOOP_EVENT_H_
@@ -0,0 +1,148 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	kernelBTFPath = "/sys/kernel/btf/vmlinux"
+
+	defaultBTFDir = "/var/lib/ethtoolsnoop/btf"
+)
+
+// btfArchs are the names of the architectures in the BTFHub layout by
+// GOARCH, which are the ones of uname -m except arm64.
+var btfArchs = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "arm64",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+	"ppc64le": "ppc64le",
+}
+
+// findBTF looks up the BTF file of the kernel release in dir, whose layout
+// may be flat or the one of BTFHub, i.e. <distro>/<version>/<arch>/. Both the
+// raw <release>.btf and the archived <release>.btf.tar.xz are accepted. In
+// the BTFHub layout, only the files under the directory of the running arch
+// are taken, as the same release may be archived for the other archs too.
+func findBTF(dir, release string) (string, error) {
+	arch := btfArchs[runtime.GOARCH]
+
+	var found string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		switch d.Name() {
+		case release + ".btf", release + ".btf.tar.xz":
+			if parent := filepath.Dir(path); parent != filepath.Clean(dir) && filepath.Base(parent) != arch {
+				return nil
+			}
+
+			found = path
+			return fs.SkipAll
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+
+	if found == "" {
+		return "", fmt.Errorf("no BTF for kernel %s in %s", release, dir)
+	}
+
+	return found, nil
+}
+
+// loadBTF loads the BTF spec from a raw BTF file, an ELF file with .BTF
+// section, or a BTFHub archive.
+func loadBTF(path string) (*btf.Spec, error) {
+	if !strings.HasSuffix(path, ".tar.xz") {
+		return btf.LoadSpec(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	xr, err := xz.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xz archive %s: %w", path, err)
+	}
+
+	tr := tar.NewReader(xr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no .btf file in archive %s", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive %s: %w", path, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg || !strings.HasSuffix(hdr.Name, ".btf") {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s from %s: %w", hdr.Name, path, err)
+		}
+
+		return btf.LoadSpecFromReader(bytes.NewReader(data))
+	}
+}
+
+// externalBTF returns the path of the BTF to resolve the CO-RE relocations
+// with, or "" to use the kernel's own BTF.
+func externalBTF() (string, error) {
+	if flags.btf != "" {
+		return flags.btf, nil
+	}
+
+	if _, err := os.Stat(kernelBTFPath); err == nil {
+		return "", nil
+	}
+
+	return findBTF(flags.btfDir, kernelRelease())
+}
+
+// kernelTypes returns the BTF spec to pass through CollectionOptions, which is
+// nil when the kernel's own BTF is used.
+func kernelTypes() (*btf.Spec, error) {
+	path, err := externalBTF()
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	spec, err := loadBTF(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load BTF from %s: %w", path, err)
+	}
+
+	return spec, nil
+}
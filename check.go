@@ -0,0 +1,280 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"golang.org/x/sys/unix"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+type checkResult struct {
+	name string
+	ok   bool
+	info string
+	hint string
+}
+
+// runCheck reports whether tracing is expected to work on this host without
+// attaching anything. It returns the exit code of the check command.
+func runCheck() int {
+	var results []checkResult
+
+	results = append(results, checkKernelVersion())
+	results = append(results, checkBTF())
+	results = append(results, checkKallsyms()...)
+	results = append(results, checkEthtoolNetlink())
+	results = append(results, checkCapabilities())
+	results = append(results, checkRlimit())
+
+	failed := 0
+	for _, r := range results {
+		status := " OK "
+		if !r.ok {
+			status = "FAIL"
+			failed++
+		}
+
+		fmt.Printf("[%s] %-40s %s\n", status, r.name, r.info)
+		if !r.ok && r.hint != "" {
+			fmt.Printf("       hint: %s\n", r.hint)
+		}
+	}
+
+	if failed != 0 {
+		fmt.Printf("\n%d of %d checks failed\n", failed, len(results))
+		return 1
+	}
+
+	fmt.Printf("\nAll %d checks passed\n", len(results))
+	return 0
+}
+
+func kernelRelease() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return ""
+	}
+
+	return unix.ByteSliceToString(uts.Release[:])
+}
+
+func checkKernelVersion() checkResult {
+	r := checkResult{
+		name: "kernel version >= 5.2",
+		hint: "upgrade the kernel to 5.2 or later",
+	}
+
+	release := kernelRelease()
+	if release == "" {
+		r.info = "failed to get kernel release"
+		return r
+	}
+
+	var major, minor int
+	if _, err := fmt.Sscanf(release, "%d.%d", &major, &minor); err != nil {
+		r.info = fmt.Sprintf("failed to parse kernel release %q: %s", release, err)
+		return r
+	}
+
+	r.ok = major > 5 || (major == 5 && minor >= 2)
+	r.info = release
+	return r
+}
+
+func checkBTF() checkResult {
+	r := checkResult{
+		name: "kernel BTF",
+		hint: "use a kernel built with CONFIG_DEBUG_INFO_BTF=y, or provide the BTF by --btf or in --btf-dir",
+	}
+
+	path, err := externalBTF()
+	if err != nil {
+		r.info = err.Error()
+		return r
+	}
+
+	if path == "" {
+		_, err = btf.LoadKernelSpec()
+		path = kernelBTFPath
+	} else {
+		_, err = loadBTF(path)
+	}
+	if err != nil {
+		r.info = err.Error()
+		return r
+	}
+
+	r.ok = true
+	r.info = path
+	return r
+}
+
+func checkKallsyms() []checkResult {
+	found := make(map[string]bool, len(tracer.KernelSymbols))
+	for _, sym := range tracer.KernelSymbols {
+		found[sym] = false
+	}
+
+	var readErr error
+	if f, err := os.Open("/proc/kallsyms"); err != nil {
+		readErr = err
+	} else {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 3 {
+				continue
+			}
+
+			if _, ok := found[fields[2]]; ok {
+				found[fields[2]] = true
+			}
+		}
+		readErr = scanner.Err()
+	}
+
+	results := make([]checkResult, 0, len(tracer.KernelSymbols))
+	for _, sym := range tracer.KernelSymbols {
+		r := checkResult{
+			name: "kallsyms " + sym,
+			ok:   found[sym],
+			hint: "the function is missing or inlined in this kernel, tracing it is not possible",
+		}
+
+		switch {
+		case readErr != nil:
+			r.info = fmt.Sprintf("failed to read /proc/kallsyms: %s", readErr)
+			r.hint = "make /proc/kallsyms readable"
+		case r.ok:
+			r.info = "found"
+		default:
+			r.info = "not found"
+		}
+
+		results = append(results, r)
+	}
+
+	return results
+}
+
+func checkEthtoolNetlink() checkResult {
+	r := checkResult{
+		name: "ethtool netlink",
+		hint: "use a kernel built with CONFIG_ETHTOOL_NETLINK=y, only ioctl will be traced",
+	}
+
+	id, err := ethtool.FamilyID()
+	if err != nil {
+		r.info = fmt.Sprintf("failed to resolve genetlink family %q: %s", ethtool.GenlName, err)
+		return r
+	}
+
+	r.ok = true
+	r.info = fmt.Sprintf("genetlink family id %d", id)
+	return r
+}
+
+func effectiveCaps() (uint64, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("no CapEff in /proc/self/status")
+}
+
+func checkCapabilities() checkResult {
+	r := checkResult{
+		name: "capabilities",
+		hint: "run as root, or grant CAP_BPF and CAP_PERFMON (CAP_SYS_ADMIN before kernel 5.8)",
+	}
+
+	caps, err := effectiveCaps()
+	if err != nil {
+		r.info = fmt.Sprintf("failed to get effective capabilities: %s", err)
+		return r
+	}
+
+	has := func(c int) bool { return caps&(1<<uint(c)) != 0 }
+
+	var names []string
+	for _, c := range []struct {
+		cap  int
+		name string
+	}{
+		{unix.CAP_SYS_ADMIN, "CAP_SYS_ADMIN"},
+		{unix.CAP_BPF, "CAP_BPF"},
+		{unix.CAP_PERFMON, "CAP_PERFMON"},
+	} {
+		if has(c.cap) {
+			names = append(names, c.name)
+		}
+	}
+
+	r.ok = has(unix.CAP_SYS_ADMIN) || (has(unix.CAP_BPF) && has(unix.CAP_PERFMON))
+	if len(names) == 0 {
+		r.info = "none of CAP_SYS_ADMIN, CAP_BPF, CAP_PERFMON"
+	} else {
+		r.info = strings.Join(names, ",")
+	}
+	return r
+}
+
+func checkRlimit() checkResult {
+	r := checkResult{
+		name: "rlimit nofile/memlock",
+		hint: "run as root or grant CAP_SYS_RESOURCE, or raise the hard limits of RLIMIT_NOFILE and RLIMIT_MEMLOCK",
+	}
+
+	if err := raiseRlimits(); err != nil {
+		r.info = err.Error()
+		return r
+	}
+
+	var nofile, memlock unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &nofile); err != nil {
+		r.info = fmt.Sprintf("failed to get nofile rlimit: %s", err)
+		return r
+	}
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &memlock); err != nil {
+		r.info = fmt.Sprintf("failed to get memlock rlimit: %s", err)
+		return r
+	}
+
+	// The memlock rlimit is left as is on the kernels accounting the bpf
+	// memory by memcg, i.e. 5.11 and later.
+	r.ok = true
+	r.info = fmt.Sprintf("nofile %s, memlock %s", rlimitValue(nofile.Cur), rlimitValue(memlock.Cur))
+	return r
+}
+
+func rlimitValue(v uint64) string {
+	if v == unix.RLIM_INFINITY {
+		return "unlimited"
+	}
+
+	return strconv.FormatUint(v, 10)
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+const defaultConfigFile = "/etc/ethtoolsnoop.yaml"
+
+type filterConfig struct {
+	Interfaces []string `yaml:"interfaces"`
+	Pids       []uint32 `yaml:"pids"`
+	Comms      []string `yaml:"comms"`
+}
+
+func (c *filterConfig) filter() tracer.Filter {
+	return tracer.Filter{
+		Ifnames: c.Interfaces,
+		Pids:    c.Pids,
+		Comms:   c.Comms,
+	}
+}
+
+// enforceConfig configures the enforcement policy, see tracer.Policy.
+// The ioctl() commands are checked before the kernel copies them, so a racing
+// thread is able to bypass the policy, and the ioctl() of the 32-bit processes
+// before Linux 6.8 is checked on x86_64 and arm64 only.
+type enforceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Commands are the globs of the commands to reject, e.g. ETHTOOL_FLASHDEV
+	// or ETHTOOL_MSG_*_SET. They default to all the set and action commands.
+	Commands         []string `yaml:"commands"`
+	AllowExecutables []string `yaml:"allow_executables"`
+	AllowCgroups     []string `yaml:"allow_cgroups"`
+}
+
+func (c *enforceConfig) policy() (tracer.Policy, error) {
+	p := tracer.MutatingPolicy()
+	if len(c.Commands) != 0 {
+		p.IoctlCmds, p.GenlCmds = nil, nil
+	}
+
+	for _, pattern := range c.Commands {
+		found := false
+		for _, cmd := range ethtool.IoctlCmds() {
+			if ok, err := path.Match(pattern, cmd.String()); err != nil {
+				return p, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			} else if ok {
+				p.IoctlCmds = append(p.IoctlCmds, cmd)
+				found = true
+			}
+		}
+		for _, cmd := range ethtool.GenlCmds() {
+			if ok, _ := path.Match(pattern, cmd.String()); ok {
+				p.GenlCmds = append(p.GenlCmds, cmd)
+				found = true
+			}
+		}
+
+		if !found {
+			return p, fmt.Errorf("no command matches %q", pattern)
+		}
+	}
+
+	p.AllowExecutables = c.AllowExecutables
+	p.AllowCgroups = c.AllowCgroups
+
+	return p, nil
+}
+
+type metricsConfig struct {
+	// Address is the address to serve the Prometheus metrics at /metrics
+	// on, e.g. 127.0.0.1:9710. Metrics are disabled if it is empty.
+	Address string `yaml:"address"`
+}
+
+// config is the configuration file of the daemon command. Mode,
+// PerfBufferSize, BTF, BTFDir, DriverOps, Stack, UnsafeShowSecrets,
+// EepromChecksum and Enforce.Enabled take effect only at start, and the others
+// are reloaded on SIGHUP.
+type config struct {
+	Mode           string `yaml:"mode"`
+	PerfBufferSize int    `yaml:"perf_buffer_size"`
+	BTF            string `yaml:"btf"`
+	BTFDir         string `yaml:"btf_dir"`
+	// DriverOps is the driver module whose ethtool_ops callbacks to trace.
+	DriverOps string `yaml:"driver_ops"`
+	// Stack is the stacks to capture: kernel, user or both.
+	Stack string `yaml:"stack"`
+	// UnsafeShowSecrets shows the RSS hash key in all the outputs. The
+	// passwords are never shown.
+	UnsafeShowSecrets bool `yaml:"unsafe_show_secrets"`
+	// EepromChecksum shows the SHA-256 prefix of the bytes written to the
+	// EEPROM.
+	EepromChecksum bool `yaml:"eeprom_checksum"`
+
+	Filter  filterConfig   `yaml:"filter"`
+	Outputs []outputConfig `yaml:"outputs"`
+	Metrics metricsConfig  `yaml:"metrics"`
+	Rules   []ruleConfig   `yaml:"rules"`
+	Enforce enforceConfig  `yaml:"enforce"`
+}
+
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &config{
+		Mode:           "all",
+		PerfBufferSize: tracer.DefaultPerfBufferSize,
+		BTFDir:         defaultBTFDir,
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if len(cfg.Outputs) == 0 {
+		cfg.Outputs = []outputConfig{{Format: "table"}}
+	}
+
+	return cfg, nil
+}
+
+// startupChanged reports whether the options taking effect only at start
+// differ between the configs.
+func (c *config) startupChanged(other *config) bool {
+	return c.Mode != other.Mode || c.PerfBufferSize != other.PerfBufferSize ||
+		c.BTF != other.BTF || c.BTFDir != other.BTFDir ||
+		c.DriverOps != other.DriverOps || c.Stack != other.Stack ||
+		c.UnsafeShowSecrets != other.UnsafeShowSecrets ||
+		c.EepromChecksum != other.EepromChecksum ||
+		c.Enforce.Enabled != other.Enforce.Enabled
+}
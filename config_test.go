@@ -0,0 +1,70 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+// The set and action commands are denied by default.
+func TestEnforcePolicyDefault(t *testing.T) {
+	cfg := enforceConfig{AllowExecutables: []string{"/usr/local/bin/agent"}}
+	p, err := cfg.policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range []ethtool.IoctlCmd{ethtool.ETHTOOL_FLASHDEV, ethtool.ETHTOOL_RESET, ethtool.ETHTOOL_SEEPROM, ethtool.ETHTOOL_SCHANNELS} {
+		if !slices.Contains(p.IoctlCmds, cmd) {
+			t.Errorf("%s is not denied", cmd)
+		}
+	}
+	for _, cmd := range []ethtool.GenlCmd{ethtool.ETHTOOL_MSG_CHANNELS_SET, ethtool.ETHTOOL_MSG_MODULE_FW_FLASH_ACT} {
+		if !slices.Contains(p.GenlCmds, cmd) {
+			t.Errorf("%s is not denied", cmd)
+		}
+	}
+	if slices.Contains(p.IoctlCmds, ethtool.ETHTOOL_GDRVINFO) || slices.Contains(p.GenlCmds, ethtool.ETHTOOL_MSG_CHANNELS_GET) {
+		t.Errorf("get commands are denied")
+	}
+	if len(p.IoctlCmds) != len(tracer.MutatingPolicy().IoctlCmds) || len(p.GenlCmds) != len(tracer.MutatingPolicy().GenlCmds) {
+		t.Errorf("default policy differs from tracer.MutatingPolicy()")
+	}
+	if len(p.AllowExecutables) != 1 {
+		t.Errorf("AllowExecutables = %q, want the configured one", p.AllowExecutables)
+	}
+}
+
+func TestEnforcePolicyCommands(t *testing.T) {
+	cfg := enforceConfig{Commands: []string{"ETHTOOL_FLASHDEV", "ETHTOOL_MSG_*_SET"}}
+	p, err := cfg.policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.IoctlCmds) != 1 || p.IoctlCmds[0] != ethtool.ETHTOOL_FLASHDEV {
+		t.Errorf("IoctlCmds = %v, want only ETHTOOL_FLASHDEV", p.IoctlCmds)
+	}
+	for _, cmd := range p.GenlCmds {
+		if cmd.Class() != ethtool.ClassSet {
+			t.Errorf("%s is denied, want only the *_SET messages", cmd)
+		}
+	}
+	if !slices.Contains(p.GenlCmds, ethtool.ETHTOOL_MSG_LINKMODES_SET) {
+		t.Errorf("ETHTOOL_MSG_LINKMODES_SET is not denied")
+	}
+}
+
+func TestEnforcePolicyInvalid(t *testing.T) {
+	for _, commands := range [][]string{{"ETHTOOL_NOPE"}, {"ETHTOOL_["}} {
+		cfg := enforceConfig{Commands: commands}
+		if _, err := cfg.policy(); err == nil {
+			t.Errorf("policy() of %q succeeded, want an error", commands)
+		}
+	}
+}
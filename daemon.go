@@ -0,0 +1,265 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+// sdNotify reports the state to systemd if it is run as a Type=notify
+// service, see sd_notify(3).
+func sdNotify(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		log.Printf("Failed to notify systemd: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("Failed to notify systemd: %s", err)
+	}
+}
+
+func monotonicUsec() int64 {
+	var ts unix.Timespec
+	_ = unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
+	return ts.Nano() / 1000
+}
+
+// daemon traces the ethtool commands continuously by the configuration file,
+// which is reloaded on SIGHUP without detaching the bpf programs.
+type daemon struct {
+	path    string
+	tracer  *tracer.Tracer
+	metrics *metrics
+
+	mu     sync.Mutex
+	cfg    *config
+	sinks  multiSink
+	rules  rules
+	server *http.Server
+}
+
+// listenMetrics listens on addr for serveMetrics, or returns nil if addr is
+// empty.
+func listenMetrics(addr string) (net.Listener, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	return ln, nil
+}
+
+// serveMetrics serves the metrics on ln instead of the previous listener, or
+// stops serving them if ln is nil.
+func (d *daemon) serveMetrics(ln net.Listener) {
+	if d.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_ = d.server.Shutdown(ctx)
+		cancel()
+		d.server = nil
+	}
+
+	if ln == nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", d.metrics)
+	d.server = &http.Server{Handler: mux}
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Failed to serve metrics: %s", err)
+		}
+	}(d.server)
+}
+
+// apply applies the reloadable part of the config. The previous config stays
+// in effect if it fails, as everything that may fail is done before any of it
+// is switched.
+func (d *daemon) apply(cfg *config) error {
+	rs, err := newRules(cfg.Rules)
+	if err != nil {
+		return fmt.Errorf("failed to create rules: %w", err)
+	}
+
+	sinks, err := newMultiSink(cfg.Outputs)
+	if err != nil {
+		return fmt.Errorf("failed to create outputs: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	metricsChanged := d.cfg == nil || d.cfg.Metrics.Address != cfg.Metrics.Address
+	var ln net.Listener
+	if metricsChanged {
+		if ln, err = listenMetrics(cfg.Metrics.Address); err != nil {
+			sinks.Close()
+			return err
+		}
+	}
+
+	if d.cfg != nil && d.cfg.Enforce.Enabled && cfg.Enforce.Enabled {
+		p, err := cfg.Enforce.policy()
+		if err == nil {
+			err = d.tracer.SetPolicy(p)
+		}
+		if err != nil {
+			if ln != nil {
+				ln.Close()
+			}
+			sinks.Close()
+			return fmt.Errorf("failed to update enforce policy: %w", err)
+		}
+	}
+
+	if d.cfg != nil && d.cfg.startupChanged(cfg) {
+		log.Printf("Changes of mode, perf_buffer_size, btf, btf_dir and enforce.enabled take effect after restart")
+	}
+
+	if metricsChanged {
+		d.serveMetrics(ln)
+	}
+	d.tracer.SetFilter(cfg.Filter.filter())
+
+	if d.sinks != nil {
+		d.sinks.Close()
+	}
+	d.sinks = sinks
+	d.rules = rs
+	d.cfg = cfg
+
+	return nil
+}
+
+func (d *daemon) reload() {
+	sdNotify(fmt.Sprintf("RELOADING=1\nMONOTONIC_USEC=%d", monotonicUsec()))
+	defer sdNotify("READY=1")
+
+	cfg, err := loadConfig(d.path)
+	if err == nil {
+		err = d.apply(cfg)
+	}
+	if err != nil {
+		log.Printf("Failed to reload %s, keep the previous config: %s", d.path, err)
+		return
+	}
+
+	log.Printf("Reloaded %s", d.path)
+}
+
+func (d *daemon) handle(ev *tracer.Event) {
+	d.metrics.observe(ev)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.sinks.write(ev); err != nil {
+		log.Printf("Failed to output event: %s", err)
+	}
+	d.rules.fire(ev)
+}
+
+func (d *daemon) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.serveMetrics(nil)
+	d.sinks.Close()
+}
+
+func runDaemon(ctx context.Context) error {
+	cfg, err := loadConfig(flags.configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	flags.mode = cfg.Mode
+	flags.perfBufferSize = cfg.PerfBufferSize
+	flags.btf = cfg.BTF
+	flags.btfDir = cfg.BTFDir
+	flags.driver = cfg.DriverOps
+	flags.stack = cfg.Stack
+	flags.showSecrets = cfg.UnsafeShowSecrets
+	flags.eepromChecksum = cfg.EepromChecksum
+
+	d := &daemon{
+		path:    flags.configFile,
+		metrics: newMetrics(),
+	}
+
+	opts := []tracer.Option{
+		tracer.WithFilter(cfg.Filter.filter()),
+		tracer.WithLostHandler(func(lost uint64) {
+			d.metrics.observeLost(lost)
+			log.Printf("Lost %d samples", lost)
+		}),
+	}
+	if cfg.Enforce.Enabled {
+		p, err := cfg.Enforce.policy()
+		if err != nil {
+			return fmt.Errorf("failed to create enforce policy: %w", err)
+		}
+		opts = append(opts, tracer.WithPolicy(p))
+	}
+
+	d.tracer, err = newTracer(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create tracer: %w", err)
+	}
+	defer d.tracer.Close()
+
+	if err := d.apply(cfg); err != nil {
+		return err
+	}
+	defer d.close()
+	defer waitActions()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, unix.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				d.reload()
+			}
+		}
+	}()
+
+	sdNotify("READY=1")
+	defer sdNotify("STOPPING=1")
+
+	log.Printf("Tracing ethtool commands by %s", d.path)
+	return d.tracer.Run(ctx, d.handle)
+}
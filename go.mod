@@ -6,8 +6,10 @@ require (
 	github.com/cilium/ebpf v0.12.3
 	github.com/spf13/pflag v1.0.5
 	github.com/tklauser/ps v0.0.2
+	github.com/ulikunitz/xz v0.5.11
 	golang.org/x/sync v0.1.0
 	golang.org/x/sys v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -4,127 +4,294 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"log"
+	"os"
 	"os/signal"
+	"strings"
 
-	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 	"github.com/cilium/ebpf/rlimit"
 	flag "github.com/spf13/pflag"
-	"golang.org/x/sync/errgroup"
 	"golang.org/x/sys/unix"
-)
 
-//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -cc clang -no-strip -no-global-types ethtool ./bpf/ethtool.c -- -D__TARGET_ARCH_x86 -I./bpf/headers
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
 
 var flags struct {
-	debug bool
+	debug          bool
+	showDriver     bool
+	btf            string
+	btfDir         string
+	ifnames        []string
+	pids           []uint
+	comms          []string
+	mode           string
+	driver         string
+	stack          string
+	showSecrets    bool
+	eepromChecksum bool
+	perfBufferSize int
+	outputs        []string
+	recordFile     string
+	rulesFile      string
+	configFile     string
+}
+
+const usage = `Usage: %s [command] [flags]
+
+Commands:
+  (none)    trace ethtool commands and print them
+  check     report whether tracing is supported on this host
+  record    trace ethtool commands and record them to a file
+  replay    print the ethtool commands recorded in a file
+  daemon    trace ethtool commands continuously by the configuration file
+
+Flags:
+%s`
+
+func addBTFFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flags.btf, "btf", "", "path to the kernel BTF, for kernels without "+kernelBTFPath)
+	fs.StringVar(&flags.btfDir, "btf-dir", defaultBTFDir, "directory to look up <uname -r>.btf[.tar.xz] in, for kernels without "+kernelBTFPath)
 }
 
-func init() {
-	flag.BoolVar(&flags.debug, "debug", false, "debug mode")
-	flag.Parse()
+func addFilterFlags(fs *flag.FlagSet) {
+	fs.StringSliceVar(&flags.ifnames, "interface", nil, "trace only the interfaces")
+	fs.UintSliceVar(&flags.pids, "pid", nil, "trace only the processes by pid")
+	fs.StringSliceVar(&flags.comms, "comm", nil, "trace only the processes by comm")
 }
 
-func main() {
+func addTraceFlags(fs *flag.FlagSet) {
+	addBTFFlags(fs)
+	addFilterFlags(fs)
+	fs.StringVar(&flags.mode, "mode", "all", "trace ethtool commands issued through: ioctl, genl, all (ioctl and genl), kernel (in-kernel consumers) or notify (genetlink notifications), comma-separated")
+	fs.StringVar(&flags.stack, "stack", "", "capture the stacks of the events: kernel, user or both")
+	fs.StringVar(&flags.driver, "driver", "", "trace the ethtool_ops callbacks of the driver module, e.g. mlx5_core")
+	fs.IntVar(&flags.perfBufferSize, "perf-buffer-size", tracer.DefaultPerfBufferSize, "size in bytes of the per-CPU perf event buffer")
+	fs.BoolVar(&flags.showSecrets, "unsafe-show-secrets", false, "show the RSS hash key in all the outputs rather than its hash; the passwords are never shown")
+	fs.BoolVar(&flags.eepromChecksum, "eeprom-checksum", false, "show the SHA-256 prefix of the bytes written to the EEPROM")
+}
+
+func addRuleFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flags.rulesFile, "rules", "", "file of the rules firing actions on the matching events")
+}
+
+func addOutputFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&flags.debug, "debug", false, "debug mode")
+	fs.BoolVar(&flags.showDriver, "show-driver", false, "show the driver and the bus address of the interface in the table")
+	fs.StringArrayVarP(&flags.outputs, "output", "o", []string{"table"}, "output as <format>[:<path>], format: table, json, logfmt, csv, syslog or journald; path defaults to stdout; repeat for several outputs")
+}
+
+// parseFlags parses the flags of the command, which is the first argument if
+// it does not start with "-".
+func parseFlags() (cmd string, args []string) {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	args = os.Args[1:]
+	if len(args) != 0 && args[0] != "" && args[0][0] != '-' {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "":
+		addTraceFlags(fs)
+		addOutputFlags(fs)
+		addRuleFlags(fs)
+	case "check":
+		addBTFFlags(fs)
+	case "record":
+		addTraceFlags(fs)
+		fs.StringVarP(&flags.recordFile, "output", "o", "", "file to record the events to")
+	case "replay":
+		addFilterFlags(fs)
+		addOutputFlags(fs)
+		addRuleFlags(fs)
+	case "daemon":
+		fs.StringVarP(&flags.configFile, "config", "c", defaultConfigFile, "configuration file")
+	}
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0], fs.FlagUsages())
+	}
+	_ = fs.Parse(args)
+
+	return cmd, fs.Args()
+}
+
+func raiseRlimits() error {
 	if err := unix.Setrlimit(unix.RLIMIT_NOFILE, &unix.Rlimit{
 		Cur: 8192,
 		Max: 8192,
 	}); err != nil {
-		log.Fatalf("failed to set temporary rlimit: %s", err)
+		return fmt.Errorf("failed to set temporary rlimit: %w", err)
 	}
 	if err := rlimit.RemoveMemlock(); err != nil {
-		log.Fatalf("Failed to set temporary rlimit: %s", err)
+		return fmt.Errorf("failed to remove memlock rlimit: %w", err)
 	}
 
-	var obj ethtoolObjects
-	if err := loadEthtoolObjects(&obj, nil); err != nil {
-		log.Fatalf("Failed to load objects: %s", err)
-	}
-	defer obj.Close()
+	return nil
+}
 
-	if kp, err := link.Kprobe("dev_ethtool", obj.KpDevEthtool, nil); err != nil {
-		log.Fatalf("Failed to create kprobe: %s", err)
-	} else {
-		defer kp.Close()
+func filter() tracer.Filter {
+	f := tracer.Filter{
+		Ifnames: flags.ifnames,
+		Comms:   flags.comms,
+	}
+	for _, pid := range flags.pids {
+		f.Pids = append(f.Pids, uint32(pid))
 	}
 
-	if krp, err := link.Kretprobe("ethnl_parse_header_dev_get", obj.KrpEthnlDev, nil); err != nil {
-		log.Fatalf("Failed to create kretprobe: %s", err)
-	} else {
-		defer krp.Close()
+	return f
+}
+
+// flagRules loads the rules file by --rules, if any.
+func flagRules() (rules, error) {
+	if flags.rulesFile == "" {
+		return nil, nil
 	}
 
-	if kp, err := link.Kprobe("ethnl_parse_header_dev_get", obj.KpEthnlDev, nil); err != nil {
-		log.Fatalf("Failed to create kprobe: %s", err)
-	} else {
-		defer kp.Close()
+	return loadRules(flags.rulesFile)
+}
+
+// attachMode parses the mode, which is a comma-separated list of ioctl, genl,
+// all, kernel and notify.
+func attachMode() (tracer.AttachMode, error) {
+	var mode tracer.AttachMode
+	for _, m := range strings.Split(flags.mode, ",") {
+		switch m {
+		case "ioctl":
+			mode |= tracer.AttachIoctl
+		case "genl":
+			mode |= tracer.AttachGenl
+		case "all":
+			mode |= tracer.AttachAll
+		case "kernel":
+			mode |= tracer.AttachKernel
+		case "notify":
+			mode |= tracer.AttachNotify
+		default:
+			return 0, fmt.Errorf("invalid mode %q", m)
+		}
 	}
 
-	if kp, err := link.Kprobe("ethnl_default_doit", obj.KpEthnlDoit, nil); err != nil {
-		log.Fatalf("Failed to create kprobe: %s", err)
-	} else {
-		defer kp.Close()
+	return mode, nil
+}
+
+// stackMode parses the stack, which is one of kernel, user and both, or empty
+// for no stack.
+func stackMode() (tracer.StackMode, error) {
+	switch flags.stack {
+	case "":
+		return 0, nil
+	case "kernel":
+		return tracer.StackKernel, nil
+	case "user":
+		return tracer.StackUser, nil
+	case "both":
+		return tracer.StackBoth, nil
+	default:
+		return 0, fmt.Errorf("invalid stack %q", flags.stack)
 	}
+}
 
-	ctx, stop := signal.NotifyContext(context.Background(), unix.SIGINT, unix.SIGTERM)
-	defer stop()
-	errg, ctx := errgroup.WithContext(ctx)
+// newTracer creates the tracer by the flags. The opts override the ones by the
+// flags.
+func newTracer(opts ...tracer.Option) (*tracer.Tracer, error) {
+	mode, err := attachMode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --mode: %w", err)
+	}
 
-	reader, err := perf.NewReader(obj.Events, 4096)
+	stack, err := stackMode()
 	if err != nil {
-		log.Fatalf("Failed to create perf event reader: %s", err)
+		return nil, fmt.Errorf("failed to parse --stack: %w", err)
 	}
 
-	errg.Go(func() error {
-		<-ctx.Done()
-		_ = reader.Close()
-		return nil
-	})
+	if err := raiseRlimits(); err != nil {
+		return nil, fmt.Errorf("failed to raise rlimits: %w", err)
+	}
 
-	errg.Go(func() error {
-		return readEvent(ctx, reader)
-	})
+	spec, err := kernelTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kernel BTF: %w", err)
+	}
 
-	if err := errg.Wait(); err != nil {
-		log.Fatalf("Error: %s", err)
+	defaults := []tracer.Option{
+		tracer.WithKernelTypes(spec),
+		tracer.WithFilter(filter()),
+		tracer.WithAttachMode(mode),
+		tracer.WithPerfBufferSize(flags.perfBufferSize),
+		tracer.WithStack(stack),
+		tracer.WithShowSecrets(flags.showSecrets),
+		tracer.WithEepromChecksum(flags.eepromChecksum),
+		tracer.WithLostHandler(func(lost uint64) {
+			log.Printf("Lost %d samples", lost)
+		}),
+	}
+	if flags.driver != "" {
+		defaults = append(defaults, tracer.WithDriverOps(flags.driver))
 	}
+
+	return tracer.New(append(defaults, opts...)...)
 }
 
-func readEvent(ctx context.Context, reader *perf.Reader) error {
-	printHeader()
-
-	var ev event
-	for {
-		record, err := reader.Read()
-		if err != nil {
-			if err == perf.ErrClosed {
-				return nil
-			}
-			select {
-			case <-ctx.Done():
-				return nil
-			default:
-				return fmt.Errorf("failed to read record: %s", err)
-			}
-		}
+func main() {
+	cmd, args := parseFlags()
+
+	ctx, stop := signal.NotifyContext(context.Background(), unix.SIGINT, unix.SIGTERM)
+	defer stop()
 
-		if record.LostSamples != 0 {
-			log.Printf("Lost %d samples", record.LostSamples)
+	switch cmd {
+	case "":
+	case "check":
+		os.Exit(runCheck())
+	case "record":
+		if err := runRecord(ctx); err != nil {
+			log.Fatalf("Failed to record: %s", err)
+		}
+		return
+	case "replay":
+		if len(args) != 1 {
+			log.Fatalf("Usage: %s replay [flags] <file>", os.Args[0])
+		}
+		if err := runReplay(args[0]); err != nil {
+			log.Fatalf("Failed to replay: %s", err)
 		}
+		return
+	case "daemon":
+		if err := runDaemon(ctx); err != nil {
+			log.Fatalf("Daemon: %s", err)
+		}
+		return
+	default:
+		fmt.Fprintf(os.Stderr, usage, os.Args[0], "")
+		log.Fatalf("Unknown command: %s", cmd)
+	}
+
+	t, err := newTracer()
+	if err != nil {
+		log.Fatalf("Failed to create tracer: %s", err)
+	}
+	defer t.Close()
 
-		binary.Read(bytes.NewReader(record.RawSample), binary.LittleEndian, &ev)
+	sinks, err := newMultiSink(parseOutputs(flags.outputs))
+	if err != nil {
+		log.Fatalf("Failed to create outputs: %s", err)
+	}
+	defer sinks.Close()
 
-		ev.print()
+	rs, err := flagRules()
+	if err != nil {
+		log.Fatalf("Failed to load rules: %s", err)
+	}
+	defer waitActions()
 
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
+	if err := t.Run(ctx, func(ev *tracer.Event) {
+		if err := sinks.write(ev); err != nil {
+			log.Printf("Failed to output event: %s", err)
 		}
+		rs.fire(ev)
+	}); err != nil {
+		log.Fatalf("Error: %s", err)
 	}
 }
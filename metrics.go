@@ -0,0 +1,85 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+type metricsKey struct {
+	typ    string
+	cmd    string
+	ifname string
+	driver string
+}
+
+// metrics counts the events and exposes them in the Prometheus text format.
+type metrics struct {
+	mu     sync.Mutex
+	events map[metricsKey]uint64
+	lost   uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{events: make(map[metricsKey]uint64)}
+}
+
+func (m *metrics) observe(ev *tracer.Event) {
+	m.mu.Lock()
+	m.events[metricsKey{ev.Type.String(), ev.Cmd(), ev.Ifname, ev.Driver}]++
+	m.mu.Unlock()
+}
+
+func (m *metrics) observeLost(lost uint64) {
+	m.mu.Lock()
+	m.lost += lost
+	m.mu.Unlock()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	keys := make([]metricsKey, 0, len(m.events))
+	for k := range m.events {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.typ != b.typ {
+			return a.typ < b.typ
+		}
+		if a.cmd != b.cmd {
+			return a.cmd < b.cmd
+		}
+		if a.ifname != b.ifname {
+			return a.ifname < b.ifname
+		}
+		return a.driver < b.driver
+	})
+
+	var sb strings.Builder
+	sb.WriteString("# HELP ethtoolsnoop_events_total Number of traced ethtool commands.\n")
+	sb.WriteString("# TYPE ethtoolsnoop_events_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "ethtoolsnoop_events_total{type=\"%s\",cmd=\"%s\",ifname=\"%s\",driver=\"%s\"} %d\n",
+			labelEscaper.Replace(k.typ), labelEscaper.Replace(k.cmd), labelEscaper.Replace(k.ifname), labelEscaper.Replace(k.driver), m.events[k])
+	}
+	sb.WriteString("# HELP ethtoolsnoop_lost_samples_total Number of events lost because the perf event buffer was full.\n")
+	sb.WriteString("# TYPE ethtoolsnoop_lost_samples_total counter\n")
+	fmt.Fprintf(&sb, "ethtoolsnoop_lost_samples_total %d\n", m.lost)
+	sb.WriteString("# HELP ethtoolsnoop_dropped_actions_total Number of rule actions dropped because too many were running.\n")
+	sb.WriteString("# TYPE ethtoolsnoop_dropped_actions_total counter\n")
+	fmt.Fprintf(&sb, "ethtoolsnoop_dropped_actions_total %d\n", droppedActions.Load())
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(sb.String()))
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// From include/uapi/linux/ethtool.h
+const (
+	ethResetSharedShift = 16
+	ethResetDedicated   = 0x0000ffff
+	ethResetAll         = 0xffffffff
+
+	ethTestFlOffline        = 1 << 0
+	ethTestFlFailed         = 1 << 1
+	ethTestFlExternalLb     = 1 << 2
+	ethTestFlExternalLbDone = 1 << 3
+
+	// The offsets in struct ethtool_test.
+	testFlags  = 4
+	testLen    = 12
+	sizeofTest = 16
+)
+
+// ethResetComponents are the names of ethtool --reset for the ETH_RESET_*
+// components by their bits, which are shifted by ethResetSharedShift for the
+// components shared with the other ports.
+var ethResetComponents = []string{"mgmt", "irq", "dma", "filter", "offload", "mac", "phy", "ram", "ap"}
+
+// resetFlags renders the ETH_RESET_* components, e.g. "irq dma phy-shared".
+func resetFlags(flags uint32) string {
+	if flags == ethResetAll {
+		return "all"
+	}
+
+	var parts []string
+	if flags&ethResetDedicated == ethResetDedicated {
+		parts = append(parts, "dedicated")
+		flags &^= ethResetDedicated
+	}
+	for _, shared := range []bool{false, true} {
+		for i, name := range ethResetComponents {
+			bit := uint32(1) << i
+			if shared {
+				bit <<= ethResetSharedShift
+				name += "-shared"
+			}
+			if flags&bit != 0 {
+				parts = append(parts, name)
+				flags &^= bit
+			}
+		}
+	}
+	if flags != 0 {
+		parts = append(parts, fmt.Sprintf("0x%x", flags))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// reset renders the components of ETHTOOL_RESET, e.g. "irq dma phy; not
+// reset: phy". The components not reset are written back on success,
+// following which the requested ones are kept.
+func (d *Decoder) reset(cmd IoctlCmd, data []byte) string {
+	switch {
+	case len(data) >= 2*sizeofEthtoolValue:
+		return resetFlags(u32At(data, sizeofEthtoolValue+4)) + "; not reset: " + resetFlags(u32At(data, 4))
+	case len(data) >= sizeofEthtoolValue:
+		return resetFlags(u32At(data, 4))
+	default:
+		return ""
+	}
+}
+
+// selfTest renders struct ethtool_test of ETHTOOL_TEST and the results
+// following it on success, e.g. "offline: FAIL, failed tests 2=1 5=4 of 7".
+// The results are indexed by the tests of the driver, which are listed by
+// ethtool -t.
+func (d *Decoder) selfTest(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofTest {
+		return ""
+	}
+
+	flags := u32At(data, testFlags)
+	s := "online"
+	switch {
+	case flags&ethTestFlExternalLb != 0:
+		s = "external_lb"
+	case flags&ethTestFlOffline != 0:
+		s = "offline"
+	}
+
+	// The results are copied only on success.
+	results := data[sizeofTest:]
+	if len(results) == 0 {
+		return s
+	}
+
+	count := int(u32At(data, testLen))
+	var failed []string
+	for i := 0; i < count && (i+1)*8 <= len(results); i++ {
+		if v := u64At(results, i*8); v != 0 {
+			failed = append(failed, fmt.Sprintf("%d=%d", i, v))
+		}
+	}
+
+	if flags&ethTestFlFailed != 0 {
+		s += ": FAIL"
+	} else {
+		s += ": PASS"
+	}
+	if len(failed) != 0 {
+		s += fmt.Sprintf(", failed tests %s of %d", strings.Join(failed, " "), count)
+	} else {
+		s += fmt.Sprintf(", %d tests", count)
+	}
+	if flags&ethTestFlExternalLb != 0 && flags&ethTestFlExternalLbDone == 0 {
+		s += ", external loopback not done"
+	}
+
+	return s
+}
+
+// physID renders the blink duration of ETHTOOL_PHYS_ID, e.g. "blink 5s", of
+// which 0 blinks until interrupted.
+func (d *Decoder) physID(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofEthtoolValue {
+		return ""
+	}
+
+	if secs := u32At(data, 4); secs != 0 {
+		return fmt.Sprintf("blink %ds", secs)
+	}
+
+	return "blink until interrupted"
+}
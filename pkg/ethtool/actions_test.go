@@ -0,0 +1,55 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+func TestReset(t *testing.T) {
+	reset := uint32(ETHTOOL_RESET)
+
+	testIoctl(t, new(Decoder), []ioctlTest{
+		// ethtool --reset eth0 irq dma phy-shared
+		{"request", ETHTOOL_RESET, u32Bytes(reset, 1<<1|1<<2|1<<(6+ethResetSharedShift)), "irq dma phy-shared"},
+		{"all", ETHTOOL_RESET, u32Bytes(reset, ethResetAll), "all"},
+		{"dedicated", ETHTOOL_RESET, u32Bytes(reset, ethResetDedicated|1<<16), "dedicated mgmt-shared"},
+		{"unknown bits", ETHTOOL_RESET, u32Bytes(reset, 1<<0|1<<12), "mgmt 0x1000"},
+		// The driver has reset all but the phy.
+		{"result", ETHTOOL_RESET, u32Bytes(reset, 1<<6, reset, 1<<1|1<<6), "irq phy; not reset: phy"},
+		{"result none", ETHTOOL_RESET, u32Bytes(reset, 0, reset, 1<<1), "irq; not reset: none"},
+		{"short", ETHTOOL_RESET, u32Bytes(reset), ""},
+	})
+}
+
+// selfTestData builds struct ethtool_test and the results following it.
+func selfTestData(flags uint32, results ...uint64) []byte {
+	data := u32Bytes(uint32(ETHTOOL_TEST), flags, 0, uint32(len(results)))
+	for _, v := range results {
+		b := make([]byte, 8)
+		putU64(b, 0, v)
+		data = append(data, b...)
+	}
+
+	return data
+}
+
+func TestSelfTest(t *testing.T) {
+	testIoctl(t, new(Decoder), []ioctlTest{
+		{"request online", ETHTOOL_TEST, selfTestData(0), "online"},
+		{"request offline", ETHTOOL_TEST, selfTestData(ethTestFlOffline), "offline"},
+		{"request external_lb", ETHTOOL_TEST, selfTestData(ethTestFlOffline | ethTestFlExternalLb), "external_lb"},
+		{"pass", ETHTOOL_TEST, selfTestData(ethTestFlOffline, 0, 0, 0), "offline: PASS, 3 tests"},
+		{"fail", ETHTOOL_TEST, selfTestData(ethTestFlOffline|ethTestFlFailed, 0, 0, 1, 0, 0, 4, 0), "offline: FAIL, failed tests 2=1 5=4 of 7"},
+		{"external_lb not done", ETHTOOL_TEST, selfTestData(ethTestFlOffline|ethTestFlExternalLb, 0), "external_lb: PASS, 1 tests, external loopback not done"},
+		{"external_lb done", ETHTOOL_TEST, selfTestData(ethTestFlOffline|ethTestFlExternalLb|ethTestFlExternalLbDone, 0), "external_lb: PASS, 1 tests"},
+		{"short", ETHTOOL_TEST, selfTestData(0)[:sizeofTest-1], ""},
+	})
+}
+
+func TestPhysID(t *testing.T) {
+	testIoctl(t, new(Decoder), []ioctlTest{
+		{"duration", ETHTOOL_PHYS_ID, u32Bytes(uint32(ETHTOOL_PHYS_ID), 5), "blink 5s"},
+		{"until interrupted", ETHTOOL_PHYS_ID, u32Bytes(uint32(ETHTOOL_PHYS_ID), 0), "blink until interrupted"},
+		{"short", ETHTOOL_PHYS_ID, u32Bytes(uint32(ETHTOOL_PHYS_ID)), ""},
+	})
+}
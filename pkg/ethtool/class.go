@@ -0,0 +1,158 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CmdClass is what an ethtool command does to the device.
+type CmdClass uint8
+
+const (
+	ClassUnknown CmdClass = iota
+	// ClassGet queries the device.
+	ClassGet
+	// ClassSet changes the configuration of the device.
+	ClassSet
+	// ClassAction triggers an action on the device, e.g. reset or flash.
+	ClassAction
+)
+
+var cmdClasses = []string{
+	"unknown",
+	"get",
+	"set",
+	"action",
+}
+
+func (c CmdClass) String() string {
+	if int(c) < len(cmdClasses) {
+		return cmdClasses[c]
+	}
+
+	return fmt.Sprintf("Unknown[%d]", uint8(c))
+}
+
+// ParseCmdClass parses the name of the class.
+func ParseCmdClass(s string) (CmdClass, error) {
+	for i, name := range cmdClasses {
+		if i != int(ClassUnknown) && name == s {
+			return CmdClass(i), nil
+		}
+	}
+
+	return ClassUnknown, fmt.Errorf("unknown command class %q", s)
+}
+
+// IoctlCmds returns the known ioctl commands.
+func IoctlCmds() []IoctlCmd {
+	var cmds []IoctlCmd
+	for i, name := range ioctlCmds {
+		if name != "" {
+			cmds = append(cmds, IoctlCmd(i))
+		}
+	}
+
+	return cmds
+}
+
+// GenlCmds returns the known genetlink messages.
+func GenlCmds() []GenlCmd {
+	var cmds []GenlCmd
+	for i, name := range genlCmds {
+		if name != "" {
+			cmds = append(cmds, GenlCmd(i))
+		}
+	}
+
+	return cmds
+}
+
+var ioctlActions = map[IoctlCmd]bool{
+	ETHTOOL_NWAY_RST: true,
+	ETHTOOL_TEST:     true,
+	ETHTOOL_PHYS_ID:  true,
+	ETHTOOL_FLASHDEV: true,
+	ETHTOOL_RESET:    true,
+}
+
+// Class returns the class of the command.
+func (cmd IoctlCmd) Class() CmdClass {
+	if ioctlActions[cmd] {
+		return ClassAction
+	}
+
+	name := strings.TrimPrefix(cmd.String(), "ETHTOOL_")
+	name = strings.TrimPrefix(name, "PHY_")
+	switch {
+	case strings.HasPrefix(name, "G"):
+		return ClassGet
+	case strings.HasPrefix(name, "S"):
+		return ClassSet
+	default:
+		return ClassUnknown
+	}
+}
+
+// genlClasses are the classes of the messages, which are listed one by one as
+// their names do not always tell, e.g. ETHTOOL_MSG_PLCA_SET_CFG.
+var genlClasses = map[GenlCmd]CmdClass{
+	ETHTOOL_MSG_STRSET_GET:          ClassGet,
+	ETHTOOL_MSG_LINKINFO_GET:        ClassGet,
+	ETHTOOL_MSG_LINKINFO_SET:        ClassSet,
+	ETHTOOL_MSG_LINKMODES_GET:       ClassGet,
+	ETHTOOL_MSG_LINKMODES_SET:       ClassSet,
+	ETHTOOL_MSG_LINKSTATE_GET:       ClassGet,
+	ETHTOOL_MSG_DEBUG_GET:           ClassGet,
+	ETHTOOL_MSG_DEBUG_SET:           ClassSet,
+	ETHTOOL_MSG_WOL_GET:             ClassGet,
+	ETHTOOL_MSG_WOL_SET:             ClassSet,
+	ETHTOOL_MSG_FEATURES_GET:        ClassGet,
+	ETHTOOL_MSG_FEATURES_SET:        ClassSet,
+	ETHTOOL_MSG_PRIVFLAGS_GET:       ClassGet,
+	ETHTOOL_MSG_PRIVFLAGS_SET:       ClassSet,
+	ETHTOOL_MSG_RINGS_GET:           ClassGet,
+	ETHTOOL_MSG_RINGS_SET:           ClassSet,
+	ETHTOOL_MSG_CHANNELS_GET:        ClassGet,
+	ETHTOOL_MSG_CHANNELS_SET:        ClassSet,
+	ETHTOOL_MSG_COALESCE_GET:        ClassGet,
+	ETHTOOL_MSG_COALESCE_SET:        ClassSet,
+	ETHTOOL_MSG_PAUSE_GET:           ClassGet,
+	ETHTOOL_MSG_PAUSE_SET:           ClassSet,
+	ETHTOOL_MSG_EEE_GET:             ClassGet,
+	ETHTOOL_MSG_EEE_SET:             ClassSet,
+	ETHTOOL_MSG_TSINFO_GET:          ClassGet,
+	ETHTOOL_MSG_CABLE_TEST_ACT:      ClassAction,
+	ETHTOOL_MSG_CABLE_TEST_TDR_ACT:  ClassAction,
+	ETHTOOL_MSG_TUNNEL_INFO_GET:     ClassGet,
+	ETHTOOL_MSG_FEC_GET:             ClassGet,
+	ETHTOOL_MSG_FEC_SET:             ClassSet,
+	ETHTOOL_MSG_MODULE_EEPROM_GET:   ClassGet,
+	ETHTOOL_MSG_STATS_GET:           ClassGet,
+	ETHTOOL_MSG_PHC_VCLOCKS_GET:     ClassGet,
+	ETHTOOL_MSG_MODULE_GET:          ClassGet,
+	ETHTOOL_MSG_MODULE_SET:          ClassSet,
+	ETHTOOL_MSG_PSE_GET:             ClassGet,
+	ETHTOOL_MSG_PSE_SET:             ClassSet,
+	ETHTOOL_MSG_RSS_GET:             ClassGet,
+	ETHTOOL_MSG_PLCA_GET_CFG:        ClassGet,
+	ETHTOOL_MSG_PLCA_SET_CFG:        ClassSet,
+	ETHTOOL_MSG_PLCA_GET_STATUS:     ClassGet,
+	ETHTOOL_MSG_MM_GET:              ClassGet,
+	ETHTOOL_MSG_MM_SET:              ClassSet,
+	ETHTOOL_MSG_MODULE_FW_FLASH_ACT: ClassAction,
+	ETHTOOL_MSG_PHY_GET:             ClassGet,
+	ETHTOOL_MSG_TSCONFIG_GET:        ClassGet,
+	ETHTOOL_MSG_TSCONFIG_SET:        ClassSet,
+	ETHTOOL_MSG_RSS_SET:             ClassSet,
+	ETHTOOL_MSG_RSS_CREATE_ACT:      ClassAction,
+	ETHTOOL_MSG_RSS_DELETE_ACT:      ClassAction,
+}
+
+// Class returns the class of the message.
+func (cmd GenlCmd) Class() CmdClass {
+	return genlClasses[cmd]
+}
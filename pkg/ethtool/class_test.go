@@ -0,0 +1,71 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+func TestParseCmdClass(t *testing.T) {
+	for _, class := range []CmdClass{ClassGet, ClassSet, ClassAction} {
+		if got, err := ParseCmdClass(class.String()); err != nil || got != class {
+			t.Errorf("ParseCmdClass(%q) = %s, %v, want %s", class.String(), got, err, class)
+		}
+	}
+
+	for _, s := range []string{"", "unknown", "Set"} {
+		if _, err := ParseCmdClass(s); err == nil {
+			t.Errorf("ParseCmdClass(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestIoctlClass(t *testing.T) {
+	for _, tt := range []struct {
+		cmd  IoctlCmd
+		want CmdClass
+	}{
+		{ETHTOOL_GDRVINFO, ClassGet},
+		{ETHTOOL_SCHANNELS, ClassSet},
+		{ETHTOOL_SEEPROM, ClassSet},
+		{ETHTOOL_PHY_GTUNABLE, ClassGet},
+		{ETHTOOL_PHY_STUNABLE, ClassSet},
+		{ETHTOOL_FLASHDEV, ClassAction},
+		{ETHTOOL_RESET, ClassAction},
+		{ETHTOOL_PHYS_ID, ClassAction},
+	} {
+		if got := tt.cmd.Class(); got != tt.want {
+			t.Errorf("%s.Class() = %s, want %s", tt.cmd, got, tt.want)
+		}
+	}
+
+	// ETHTOOL_PERQUEUE is classified by the commands it wraps, which are
+	// read by the bpf programs in place of it.
+	for _, cmd := range IoctlCmds() {
+		if cmd != ETHTOOL_PERQUEUE && cmd.Class() == ClassUnknown {
+			t.Errorf("%s has no class", cmd)
+		}
+	}
+}
+
+// Every message sent by the user space has a class, as the unknown ones are
+// never denied by the enforcement policy.
+func TestGenlClass(t *testing.T) {
+	for _, cmd := range GenlCmds() {
+		if cmd != ETHTOOL_MSG_USER_NONE && cmd.Class() == ClassUnknown {
+			t.Errorf("%s has no class", cmd)
+		}
+	}
+
+	for _, tt := range []struct {
+		cmd  GenlCmd
+		want CmdClass
+	}{
+		{ETHTOOL_MSG_PLCA_GET_CFG, ClassGet},
+		{ETHTOOL_MSG_PLCA_SET_CFG, ClassSet},
+		{ETHTOOL_MSG_MODULE_FW_FLASH_ACT, ClassAction},
+	} {
+		if got := tt.cmd.Class(); got != tt.want {
+			t.Errorf("%s.Class() = %s, want %s", tt.cmd, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Decoder renders the structs passed by the ioctl commands and the attributes
+// of the genetlink messages in the style of the ethtool options.
+type Decoder struct {
+	// Features are the names of the netdev features by their bits, i.e. the
+	// ETH_SS_FEATURES string set of the kernel. The features missing here
+	// are shown by their bits.
+	Features []string
+	// LinkModes are the names of the link modes by their bits, i.e. the
+	// ETH_SS_LINK_MODES string set of the kernel.
+	LinkModes []string
+	// ShowSecrets shows the RSS hash key rather than its hash. The
+	// passwords, e.g. the SecureOn password of wake-on-LAN, are never shown.
+	ShowSecrets bool
+	// EepromChecksum shows the prefix of the SHA-256 of the bytes written to
+	// the EEPROM by ETHTOOL_SEEPROM.
+	EepromChecksum bool
+}
+
+// ioctlDecoders render the structs passed by the ioctl commands.
+var ioctlDecoders = map[IoctlCmd]func(d *Decoder, cmd IoctlCmd, data []byte) string{
+	ETHTOOL_GRXCSUM:       (*Decoder).value,
+	ETHTOOL_SRXCSUM:       (*Decoder).value,
+	ETHTOOL_GTXCSUM:       (*Decoder).value,
+	ETHTOOL_STXCSUM:       (*Decoder).value,
+	ETHTOOL_GSG:           (*Decoder).value,
+	ETHTOOL_SSG:           (*Decoder).value,
+	ETHTOOL_GTSO:          (*Decoder).value,
+	ETHTOOL_STSO:          (*Decoder).value,
+	ETHTOOL_GUFO:          (*Decoder).value,
+	ETHTOOL_SUFO:          (*Decoder).value,
+	ETHTOOL_GGSO:          (*Decoder).value,
+	ETHTOOL_SGSO:          (*Decoder).value,
+	ETHTOOL_GFLAGS:        (*Decoder).flags,
+	ETHTOOL_SFLAGS:        (*Decoder).flags,
+	ETHTOOL_GGRO:          (*Decoder).value,
+	ETHTOOL_SGRO:          (*Decoder).value,
+	ETHTOOL_GRXFH:         (*Decoder).rxnfc,
+	ETHTOOL_SRXFH:         (*Decoder).rxnfc,
+	ETHTOOL_GRXCLSRULE:    (*Decoder).rxnfc,
+	ETHTOOL_SRXCLSRLDEL:   (*Decoder).rxnfc,
+	ETHTOOL_SRXCLSRLINS:   (*Decoder).rxnfc,
+	ETHTOOL_SWOL:          (*Decoder).swol,
+	ETHTOOL_SMSGLVL:       (*Decoder).smsglvl,
+	ETHTOOL_SSET:          (*Decoder).ethtoolCmd,
+	ETHTOOL_SLINKSETTINGS: (*Decoder).linkSettings,
+	ETHTOOL_GTUNABLE:      (*Decoder).tunable,
+	ETHTOOL_STUNABLE:      (*Decoder).tunable,
+	ETHTOOL_PHY_GTUNABLE:  (*Decoder).tunable,
+	ETHTOOL_PHY_STUNABLE:  (*Decoder).tunable,
+	ETHTOOL_TEST:          (*Decoder).selfTest,
+	ETHTOOL_PHYS_ID:       (*Decoder).physID,
+	ETHTOOL_RESET:         (*Decoder).reset,
+	ETHTOOL_FLASHDEV:      (*Decoder).flashdev,
+	ETHTOOL_GEEPROM:       (*Decoder).eeprom,
+	ETHTOOL_SEEPROM:       (*Decoder).eeprom,
+	ETHTOOL_GMODULEEEPROM: (*Decoder).eeprom,
+	ETHTOOL_SRXNTUPLE:     (*Decoder).rxnfc,
+	ETHTOOL_GRXFHINDIR:    (*Decoder).rxfhIndir,
+	ETHTOOL_SRXFHINDIR:    (*Decoder).rxfhIndir,
+	ETHTOOL_GFEATURES:     (*Decoder).gfeatures,
+	ETHTOOL_SFEATURES:     (*Decoder).sfeatures,
+	ETHTOOL_GRSSH:         (*Decoder).rxfh,
+	ETHTOOL_SRSSH:         (*Decoder).rxfh,
+}
+
+// genlDecoders render the attributes of the genetlink messages.
+var genlDecoders = map[GenlCmd]func(d *Decoder, cmd GenlCmd, data []byte) string{
+	ETHTOOL_MSG_DEBUG_SET:           (*Decoder).debugMsg,
+	ETHTOOL_MSG_WOL_SET:             (*Decoder).wolMsg,
+	ETHTOOL_MSG_LINKINFO_SET:        (*Decoder).linkInfoMsg,
+	ETHTOOL_MSG_LINKMODES_SET:       (*Decoder).linkModesMsg,
+	ETHTOOL_MSG_FEATURES_SET:        (*Decoder).featuresMsg,
+	ETHTOOL_MSG_MODULE_EEPROM_GET:   (*Decoder).moduleEeprom,
+	ETHTOOL_MSG_MODULE_FW_FLASH_ACT: (*Decoder).moduleFlash,
+	ETHTOOL_MSG_RSS_SET:             (*Decoder).rssMsg,
+	ETHTOOL_MSG_RSS_CREATE_ACT:      (*Decoder).rssMsg,
+	ETHTOOL_MSG_RSS_DELETE_ACT:      (*Decoder).rssMsg,
+}
+
+// notifyDecoders render the attributes of the genetlink messages sent by the
+// kernel.
+var notifyDecoders = map[NotifyCmd]func(d *Decoder, cmd NotifyCmd, data []byte) string{
+	ETHTOOL_MSG_MODULE_FW_FLASH_NTF: (*Decoder).moduleFlashNtf,
+}
+
+// Ioctl renders the struct passed by the command, as copied from the user
+// memory. It returns empty if the command is not decoded or the data is too
+// short.
+func (d *Decoder) Ioctl(cmd IoctlCmd, data []byte) string {
+	if dec := ioctlDecoders[cmd]; dec != nil {
+		return dec(d, cmd, data)
+	}
+
+	return ""
+}
+
+// Genl renders the attributes of the message, as copied from the kernel. It
+// returns empty if the message is not decoded.
+func (d *Decoder) Genl(cmd GenlCmd, data []byte) string {
+	if dec := genlDecoders[cmd]; dec != nil {
+		return dec(d, cmd, data)
+	}
+
+	return ""
+}
+
+// Notify renders the attributes of the message sent by the kernel, as copied
+// from the kernel. It returns empty if the message is not decoded.
+func (d *Decoder) Notify(cmd NotifyCmd, data []byte) string {
+	if dec := notifyDecoders[cmd]; dec != nil {
+		return dec(d, cmd, data)
+	}
+
+	return ""
+}
+
+// feature returns the name of the netdev feature, e.g. rx-gro-hw, or
+// "feature-<bit>" if it is unknown.
+func (d *Decoder) feature(bit int) string {
+	if d != nil && bit < len(d.Features) && d.Features[bit] != "" {
+		return d.Features[bit]
+	}
+
+	return fmt.Sprintf("feature-%d", bit)
+}
+
+// linkMode returns the name of the link mode, e.g. 25000baseSR/Full, or
+// "link-mode-<bit>" if it is unknown.
+func (d *Decoder) linkMode(bit int) string {
+	if d != nil && bit < len(d.LinkModes) && d.LinkModes[bit] != "" {
+		return d.LinkModes[bit]
+	}
+
+	return fmt.Sprintf("link-mode-%d", bit)
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02x", c)
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+
+	return "off"
+}
+
+// cString returns the NUL-terminated string in b.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+func u16At(b []byte, off int) uint16 {
+	return binary.NativeEndian.Uint16(b[off:])
+}
+
+func u32At(b []byte, off int) uint32 {
+	return binary.NativeEndian.Uint32(b[off:])
+}
+
+func u64At(b []byte, off int) uint64 {
+	return binary.NativeEndian.Uint64(b[off:])
+}
+
+func putU32(b []byte, off int, v uint32) {
+	binary.NativeEndian.PutUint32(b[off:], v)
+}
+
+func putU64(b []byte, off int, v uint64) {
+	binary.NativeEndian.PutUint64(b[off:], v)
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// attr builds the netlink attribute, padded to NLA_ALIGNTO.
+func attr(typ uint16, val []byte) []byte {
+	b := make([]byte, nlAlign(unix.SizeofNlAttr+len(val)))
+	binary.NativeEndian.PutUint16(b, uint16(unix.SizeofNlAttr+len(val)))
+	binary.NativeEndian.PutUint16(b[2:], typ)
+	copy(b[unix.SizeofNlAttr:], val)
+
+	return b
+}
+
+// nested builds the nested netlink attribute of the attributes, with
+// NLA_F_NESTED set as the kernel does.
+func nested(typ uint16, attrs ...[]byte) []byte {
+	var val []byte
+	for _, a := range attrs {
+		val = append(val, a...)
+	}
+
+	return attr(typ|unix.NLA_F_NESTED, val)
+}
+
+func attrU32(typ uint16, v uint32) []byte {
+	return attr(typ, u32Bytes(v))
+}
+
+func attrU64(typ uint16, v uint64) []byte {
+	b := make([]byte, 8)
+	binary.NativeEndian.PutUint64(b, v)
+
+	return attr(typ, b)
+}
+
+func attrString(typ uint16, s string) []byte {
+	return attr(typ, append([]byte(s), 0))
+}
+
+// concat builds the attributes of a message.
+func concat(attrs ...[]byte) []byte {
+	var b []byte
+	for _, a := range attrs {
+		b = append(b, a...)
+	}
+
+	return b
+}
+
+// u32Bytes encodes the u32s in the host byte order, as in the netlink attributes
+// and the ioctl structs.
+func u32Bytes(vals ...uint32) []byte {
+	b := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		putU32(b, i*4, v)
+	}
+
+	return b
+}
+
+// ioctlTest is a case of rendering the struct passed by an ioctl command.
+type ioctlTest struct {
+	name string
+	cmd  IoctlCmd
+	data []byte
+	want string
+}
+
+func testIoctl(t *testing.T, d *Decoder, tests []ioctlTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Ioctl(tt.cmd, tt.data); got != tt.want {
+				t.Errorf("Ioctl(%s) = %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+// genlTest is a case of rendering the attributes of a genetlink message.
+type genlTest struct {
+	name string
+	cmd  GenlCmd
+	data []byte
+	want string
+}
+
+func testGenl(t *testing.T, d *Decoder, tests []genlTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Genl(tt.cmd, tt.data); got != tt.want {
+				t.Errorf("Genl(%s) = %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+// notifyTest is a case of rendering the attributes of a genetlink message sent
+// by the kernel.
+type notifyTest struct {
+	name string
+	cmd  NotifyCmd
+	data []byte
+	want string
+}
+
+func testNotify(t *testing.T, d *Decoder, tests []notifyTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Notify(tt.cmd, tt.data); got != tt.want {
+				t.Errorf("Notify(%s) = %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// From include/uapi/linux/ethtool.h and include/uapi/linux/ethtool_netlink.h
+const (
+	// The offsets in struct ethtool_eeprom.
+	eepromMagic  = 4
+	eepromOffset = 8
+	eepromLen    = 12
+	sizeofEeprom = 16
+
+	ETHTOOL_A_MODULE_EEPROM_OFFSET      = 2
+	ETHTOOL_A_MODULE_EEPROM_LENGTH      = 3
+	ETHTOOL_A_MODULE_EEPROM_PAGE        = 4
+	ETHTOOL_A_MODULE_EEPROM_BANK        = 5
+	ETHTOOL_A_MODULE_EEPROM_I2C_ADDRESS = 6
+)
+
+// eeprom renders struct ethtool_eeprom of ETHTOOL_GEEPROM, ETHTOOL_SEEPROM
+// and ETHTOOL_GMODULEEEPROM, e.g. "read offset 0 length 128" or "write magic
+// 0x15b88086 offset 16 length 1 value 0x2a". The bytes written are hashed by
+// SHA-256 if there are more than one and d.EepromChecksum is set.
+func (d *Decoder) eeprom(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofEeprom {
+		return ""
+	}
+
+	op := "read"
+	if cmd == ETHTOOL_SEEPROM {
+		op = "write"
+	}
+
+	parts := []string{op}
+	if magic := u32At(data, eepromMagic); magic != 0 {
+		parts = append(parts, fmt.Sprintf("magic 0x%x", magic))
+	}
+	length := int(u32At(data, eepromLen))
+	parts = append(parts, fmt.Sprintf("offset %d length %d", u32At(data, eepromOffset), length))
+
+	// The bytes written are copied only if they fit in the data.
+	if value := data[sizeofEeprom:]; cmd == ETHTOOL_SEEPROM && length != 0 && len(value) == length {
+		if length == 1 {
+			parts = append(parts, fmt.Sprintf("value 0x%02x", value[0]))
+		} else if d != nil && d.EepromChecksum {
+			sum := sha256.Sum256(value)
+			parts = append(parts, fmt.Sprintf("sha256:%x", sum[:8]))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// moduleEepromAttrs are the attributes of ETHTOOL_MSG_MODULE_EEPROM_GET in the
+// order of ethtool -m, and their formats.
+var moduleEepromAttrs = []struct {
+	typ    uint16
+	format string
+}{
+	{ETHTOOL_A_MODULE_EEPROM_OFFSET, "offset %d"},
+	{ETHTOOL_A_MODULE_EEPROM_LENGTH, "length %d"},
+	{ETHTOOL_A_MODULE_EEPROM_PAGE, "page %d"},
+	{ETHTOOL_A_MODULE_EEPROM_BANK, "bank %d"},
+	{ETHTOOL_A_MODULE_EEPROM_I2C_ADDRESS, "i2c 0x%02x"},
+}
+
+// moduleEeprom renders ETHTOOL_MSG_MODULE_EEPROM_GET, e.g. "read offset 128
+// length 128 page 3 bank 0 i2c 0x50".
+func (d *Decoder) moduleEeprom(cmd GenlCmd, data []byte) string {
+	// The offset and the length are u32, and the others are u8.
+	values := make(map[uint16]uint32)
+	for _, attr := range parseAttrs(data) {
+		switch {
+		case len(attr.val) >= 4:
+			values[attr.typ] = u32At(attr.val, 0)
+		case len(attr.val) >= 1:
+			values[attr.typ] = uint32(attr.val[0])
+		}
+	}
+
+	parts := []string{"read"}
+	for _, a := range moduleEepromAttrs {
+		if v, ok := values[a.typ]; ok {
+			parts = append(parts, fmt.Sprintf(a.format, v))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
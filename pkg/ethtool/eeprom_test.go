@@ -0,0 +1,65 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestEeprom(t *testing.T) {
+	value := []byte{0xde, 0xad, 0xbe, 0xef}
+	sum := sha256.Sum256(value)
+
+	testIoctl(t, new(Decoder), []ioctlTest{
+		{
+			// ethtool -e eth0 offset 0 length 128
+			name: "read",
+			cmd:  ETHTOOL_GEEPROM,
+			data: u32Bytes(uint32(ETHTOOL_GEEPROM), 0, 0, 128),
+			want: "read offset 0 length 128",
+		},
+		{
+			// ethtool -E eth0 magic 0x15b88086 offset 16 value 0x2a
+			name: "write byte",
+			cmd:  ETHTOOL_SEEPROM,
+			data: append(u32Bytes(uint32(ETHTOOL_SEEPROM), 0x15b88086, 16, 1), 0x2a),
+			want: "write magic 0x15b88086 offset 16 length 1 value 0x2a",
+		},
+		{
+			name: "write bytes",
+			cmd:  ETHTOOL_SEEPROM,
+			data: append(u32Bytes(uint32(ETHTOOL_SEEPROM), 0x15b88086, 0, 4), value...),
+			want: "write magic 0x15b88086 offset 0 length 4",
+		},
+		{
+			name: "write truncated",
+			cmd:  ETHTOOL_SEEPROM,
+			data: append(u32Bytes(uint32(ETHTOOL_SEEPROM), 0, 0, 8), value...),
+			want: "write offset 0 length 8",
+		},
+		{
+			name: "short",
+			cmd:  ETHTOOL_GEEPROM,
+			data: u32Bytes(uint32(ETHTOOL_GEEPROM), 0, 0),
+			want: "",
+		},
+	})
+
+	testIoctl(t, &Decoder{EepromChecksum: true}, []ioctlTest{
+		{
+			name: "write bytes checksum",
+			cmd:  ETHTOOL_SEEPROM,
+			data: append(u32Bytes(uint32(ETHTOOL_SEEPROM), 0x15b88086, 0, 4), value...),
+			want: fmt.Sprintf("write magic 0x15b88086 offset 0 length 4 sha256:%x", sum[:8]),
+		},
+		{
+			name: "write truncated checksum",
+			cmd:  ETHTOOL_SEEPROM,
+			data: append(u32Bytes(uint32(ETHTOOL_SEEPROM), 0, 0, 8), value...),
+			want: "write offset 0 length 8",
+		},
+	})
+}
@@ -1,14 +1,17 @@
 // Copyright 2024 Leon Hwang.
 // SPDX-License-Identifier: Apache-2.0
 
-package main
+// Package ethtool provides the catalogs of the ethtool ioctl commands and the
+// ethtool genetlink messages, and the ethtool options they are issued by.
+package ethtool
 
 import (
 	"fmt"
 	"strings"
 )
 
-type ethIoctlCmd uint16
+// IoctlCmd is the ETHTOOL_* command of the SIOCETHTOOL ioctl.
+type IoctlCmd uint16
 
 const (
 	ETHTOOL_GSET     = 0x00000001 /* DEPRECATED, Get settings. */
@@ -94,7 +97,7 @@ const (
 	ETHTOOL_SFECPARAM     = 0x00000051 /* Set FEC settings */
 )
 
-var ethIoctlCmds = []string{
+var ioctlCmds = []string{
 	"",
 	"ETHTOOL_GSET",
 	"ETHTOOL_SSET",
@@ -179,64 +182,65 @@ var ethIoctlCmds = []string{
 	"ETHTOOL_SFECPARAM",
 }
 
-func (cmd ethIoctlCmd) String() string {
-	if int(cmd) < len(ethIoctlCmds) {
-		return ethIoctlCmds[cmd]
+func (cmd IoctlCmd) String() string {
+	if int(cmd) < len(ioctlCmds) {
+		return ioctlCmds[cmd]
 	}
 
 	return fmt.Sprintf("Unknown[%x]", int(cmd))
 }
 
-var ethtoolOptions = map[string]string{
-	"--get-phy-tunable": "--get-phy-tunable(Get PHY tunable)",
-	"--get-tunable":     "--get-tunable(Get tunable)",
-	"--phy-statistics":  "--phy-statistics(Show phy statistics)",
-	"--reset":           "--reset(Reset components)",
-	"--set-eee":         "--set-eee(Set EEE settings)",
-	"--set-fec":         "--set-fec(Set FEC settings)",
-	"--set-phy-tunable": "--set-phy-tunable(Set PHY tunable)",
-	"--set-priv-flags":  "--set-priv-flags(Set private flags)",
-	"--set-pse":         "--set-pse(Set Power Sourcing Equipment settings)",
-	"--set-tunable":     "--set-tunable(Set tunable)",
-	"--show-eee":        "--show-eee(Show EEE settings)",
-	"--show-fec":        "--show-fec(Show FEC settings)",
-	"--show-priv-flags": "--show-priv-flags(Query private flags)",
-	"-A":                "-A|--pause(Set pause options)",
-	"-C":                "-C|--coalesce(Set coalesce options)",
-	"-E":                "-E|--change-eeprom(Change bytes in device EEPROM)",
-	"-G":                "-G|--set-ring(Set RX/TX ring parameters)",
-	"-K":                "-K|--features|--offload(Set protocol offload and other features)",
-	"-L":                "-L|--set-channels(Set Channels)",
-	"-N":                "-N|-U|--config-nfc|--config-ntuple(Configure Rx network flow classification options or rules)",
-	"-P":                "-P|--show-permaddr(Show permanent hardware address)",
-	"-Q":                "-Q|--per-queue(Apply per-queue command.)",
-	"-S":                "-S|--statistics(Show adapter statistics)",
-	"-T":                "-T|--show-time-stamping(Show time stamping capabilities)",
-	"-W":                "-W|--set-dump(Set dump flag of the device)",
-	"-X":                "-X|--set-rxfh-indir|--rxfh(Set Rx flow hash indirection table and/or RSS hash key)",
-	"-a":                "-a|--show-pause(Show pause options)",
-	"-c":                "-c|--show-coalesce(Show coalesce options)",
-	"-d":                "-d|--register-dump(Do a register dump)",
-	"-e":                "-e|--eeprom-dump(Do a EEPROM dump)",
-	"-f":                "-f|--flash(Flash firmware image from the specified file to a region on the device)",
-	"-g":                "-g|--show-ring(Query RX/TX ring parameters)",
-	"-i":                "-i|--driver(Show driver information)",
-	"-k":                "-k|--show-features|--show-offload(Get state of protocol offload and other features)",
-	"-l":                "-l|--show-channels(Query Channels)",
-	"-m":                "-m|--dump-module-eeprom|--module-info(Query/Decode Module EEPROM information and optical diagnostics if available)",
-	"-n":                "-n|-u|--show-nfc|--show-ntuple(Show Rx network flow classification options or rules)",
-	"-p":                "-p|--identify(Show visible port identification (e.g. blinking))",
-	"-r":                "-r|--negotiate(Restart N-WAY negotiation)",
-	"-s":                "-s|--change(Change generic options)",
-	"-t":                "-t|--test(Execute adapter self test)",
-	"-w":                "-w|--get-dump(Get dump flag, data)",
-	"-x":                "-x|--show-rxfh-indir|--show-rxfh(Show Rx flow hash indirection table and/or RSS hash key)",
-	"<default>":         "<default>(Display standard information about device)",
+var options = map[string]string{
+	"--get-phy-tunable":       "--get-phy-tunable(Get PHY tunable)",
+	"--flash-module-firmware": "--flash-module-firmware(Flash transceiver module firmware)",
+	"--get-tunable":           "--get-tunable(Get tunable)",
+	"--phy-statistics":        "--phy-statistics(Show phy statistics)",
+	"--reset":                 "--reset(Reset components)",
+	"--set-eee":               "--set-eee(Set EEE settings)",
+	"--set-fec":               "--set-fec(Set FEC settings)",
+	"--set-phy-tunable":       "--set-phy-tunable(Set PHY tunable)",
+	"--set-priv-flags":        "--set-priv-flags(Set private flags)",
+	"--set-pse":               "--set-pse(Set Power Sourcing Equipment settings)",
+	"--set-tunable":           "--set-tunable(Set tunable)",
+	"--show-eee":              "--show-eee(Show EEE settings)",
+	"--show-fec":              "--show-fec(Show FEC settings)",
+	"--show-priv-flags":       "--show-priv-flags(Query private flags)",
+	"-A":                      "-A|--pause(Set pause options)",
+	"-C":                      "-C|--coalesce(Set coalesce options)",
+	"-E":                      "-E|--change-eeprom(Change bytes in device EEPROM)",
+	"-G":                      "-G|--set-ring(Set RX/TX ring parameters)",
+	"-K":                      "-K|--features|--offload(Set protocol offload and other features)",
+	"-L":                      "-L|--set-channels(Set Channels)",
+	"-N":                      "-N|-U|--config-nfc|--config-ntuple(Configure Rx network flow classification options or rules)",
+	"-P":                      "-P|--show-permaddr(Show permanent hardware address)",
+	"-Q":                      "-Q|--per-queue(Apply per-queue command.)",
+	"-S":                      "-S|--statistics(Show adapter statistics)",
+	"-T":                      "-T|--show-time-stamping(Show time stamping capabilities)",
+	"-W":                      "-W|--set-dump(Set dump flag of the device)",
+	"-X":                      "-X|--set-rxfh-indir|--rxfh(Set Rx flow hash indirection table and/or RSS hash key)",
+	"-a":                      "-a|--show-pause(Show pause options)",
+	"-c":                      "-c|--show-coalesce(Show coalesce options)",
+	"-d":                      "-d|--register-dump(Do a register dump)",
+	"-e":                      "-e|--eeprom-dump(Do a EEPROM dump)",
+	"-f":                      "-f|--flash(Flash firmware image from the specified file to a region on the device)",
+	"-g":                      "-g|--show-ring(Query RX/TX ring parameters)",
+	"-i":                      "-i|--driver(Show driver information)",
+	"-k":                      "-k|--show-features|--show-offload(Get state of protocol offload and other features)",
+	"-l":                      "-l|--show-channels(Query Channels)",
+	"-m":                      "-m|--dump-module-eeprom|--module-info(Query/Decode Module EEPROM information and optical diagnostics if available)",
+	"-n":                      "-n|-u|--show-nfc|--show-ntuple(Show Rx network flow classification options or rules)",
+	"-p":                      "-p|--identify(Show visible port identification (e.g. blinking))",
+	"-r":                      "-r|--negotiate(Restart N-WAY negotiation)",
+	"-s":                      "-s|--change(Change generic options)",
+	"-t":                      "-t|--test(Execute adapter self test)",
+	"-w":                      "-w|--get-dump(Get dump flag, data)",
+	"-x":                      "-x|--show-rxfh-indir|--show-rxfh(Show Rx flow hash indirection table and/or RSS hash key)",
+	"<default>":               "<default>(Display standard information about device)",
 }
 
-var ethIoctlCmdMsgs = map[ethIoctlCmd]string{
-	ETHTOOL_GSET:          "",
-	ETHTOOL_SSET:          "",
+var ioctlCmdMsgs = map[IoctlCmd]string{
+	ETHTOOL_GSET:          "<default>,-s",
+	ETHTOOL_SSET:          "-s",
 	ETHTOOL_GDRVINFO:      "-d,-e,-i",
 	ETHTOOL_GREGS:         "-d",
 	ETHTOOL_GWOL:          "<default>,-s",
@@ -280,17 +284,17 @@ var ethIoctlCmdMsgs = map[ethIoctlCmd]string{
 	ETHTOOL_SGRO:          "",
 	ETHTOOL_GRXRINGS:      "-n,-x,-X",
 	ETHTOOL_GRXCLSRLCNT:   "-n",
-	ETHTOOL_GRXCLSRULE:    "",
-	ETHTOOL_GRXCLSRLALL:   "",
-	ETHTOOL_SRXCLSRLDEL:   "",
-	ETHTOOL_SRXCLSRLINS:   "",
+	ETHTOOL_GRXCLSRULE:    "-n",
+	ETHTOOL_GRXCLSRLALL:   "-n",
+	ETHTOOL_SRXCLSRLDEL:   "-N",
+	ETHTOOL_SRXCLSRLINS:   "-N",
 	ETHTOOL_FLASHDEV:      "-f",
 	ETHTOOL_RESET:         "--reset",
-	ETHTOOL_SRXNTUPLE:     "",
-	ETHTOOL_GRXNTUPLE:     "",
+	ETHTOOL_SRXNTUPLE:     "-N",
+	ETHTOOL_GRXNTUPLE:     "-n",
 	ETHTOOL_GSSET_INFO:    "--phy-statistics,-t,-x,-S",
-	ETHTOOL_GRXFHINDIR:    "",
-	ETHTOOL_SRXFHINDIR:    "",
+	ETHTOOL_GRXFHINDIR:    "-x",
+	ETHTOOL_SRXFHINDIR:    "-X",
 	ETHTOOL_GFEATURES:     "-C",
 	ETHTOOL_SFEATURES:     "-K",
 	ETHTOOL_GCHANNELS:     "-l,-L",
@@ -309,8 +313,8 @@ var ethIoctlCmdMsgs = map[ethIoctlCmd]string{
 	ETHTOOL_STUNABLE:      "--set-tunable",
 	ETHTOOL_GPHYSTATS:     "--phy-statistics",
 	ETHTOOL_PERQUEUE:      "",
-	ETHTOOL_GLINKSETTINGS: "-K",
-	ETHTOOL_SLINKSETTINGS: "",
+	ETHTOOL_GLINKSETTINGS: "<default>,-s",
+	ETHTOOL_SLINKSETTINGS: "-s",
 	ETHTOOL_PHY_GTUNABLE:  "--get-phy-tunable",
 	ETHTOOL_PHY_STUNABLE:  "--set-phy-tunable",
 	ETHTOOL_GFECPARAM:     "--show-fec",
@@ -318,32 +322,34 @@ var ethIoctlCmdMsgs = map[ethIoctlCmd]string{
 }
 
 func init() {
-	for k, v := range ethIoctlCmdMsgs {
+	for k, v := range ioctlCmdMsgs {
 		if v == "" {
 			continue
 		}
 
 		var msgs []string
 		for _, opt := range strings.Split(v, ",") {
-			if o := ethtoolOptions[opt]; o != "" {
+			if o := options[opt]; o != "" {
 				msgs = append(msgs, o)
 			}
 		}
 
 		if len(msgs) > 0 {
-			ethIoctlCmdMsgs[k] = strings.Join(msgs, ", ")
+			ioctlCmdMsgs[k] = strings.Join(msgs, ", ")
 		}
 	}
 }
 
-func (cmd ethIoctlCmd) Message() string {
-	return ethIoctlCmdMsgs[cmd]
+// Message returns the ethtool options which may issue the command.
+func (cmd IoctlCmd) Message() string {
+	return ioctlCmdMsgs[cmd]
 }
 
-type ethGenlCmd uint8
+// GenlCmd is the ETHTOOL_MSG_* command of the ethtool genetlink message.
+type GenlCmd uint8
 
 const (
-	ETHTOOL_MSG_USER_NONE ethGenlCmd = iota
+	ETHTOOL_MSG_USER_NONE GenlCmd = iota
 	ETHTOOL_MSG_STRSET_GET
 	ETHTOOL_MSG_LINKINFO_GET
 	ETHTOOL_MSG_LINKINFO_SET
@@ -387,9 +393,16 @@ const (
 	ETHTOOL_MSG_PLCA_GET_STATUS
 	ETHTOOL_MSG_MM_GET
 	ETHTOOL_MSG_MM_SET
+	ETHTOOL_MSG_MODULE_FW_FLASH_ACT
+	ETHTOOL_MSG_PHY_GET
+	ETHTOOL_MSG_TSCONFIG_GET
+	ETHTOOL_MSG_TSCONFIG_SET
+	ETHTOOL_MSG_RSS_SET
+	ETHTOOL_MSG_RSS_CREATE_ACT
+	ETHTOOL_MSG_RSS_DELETE_ACT
 )
 
-var ethGenlCmds = []string{
+var genlCmds = []string{
 	"ETHTOOL_MSG_USER_NONE",
 	"ETHTOOL_MSG_STRSET_GET",
 	"ETHTOOL_MSG_LINKINFO_GET",
@@ -434,82 +447,97 @@ var ethGenlCmds = []string{
 	"ETHTOOL_MSG_PLCA_GET_STATUS",
 	"ETHTOOL_MSG_MM_GET",
 	"ETHTOOL_MSG_MM_SET",
+	"ETHTOOL_MSG_MODULE_FW_FLASH_ACT",
+	"ETHTOOL_MSG_PHY_GET",
+	"ETHTOOL_MSG_TSCONFIG_GET",
+	"ETHTOOL_MSG_TSCONFIG_SET",
+	"ETHTOOL_MSG_RSS_SET",
+	"ETHTOOL_MSG_RSS_CREATE_ACT",
+	"ETHTOOL_MSG_RSS_DELETE_ACT",
 }
 
-func (cmd ethGenlCmd) String() string {
-	if int(cmd) < len(ethGenlCmds) {
-		return ethGenlCmds[cmd]
+func (cmd GenlCmd) String() string {
+	if int(cmd) < len(genlCmds) {
+		return genlCmds[cmd]
 	}
 
 	return fmt.Sprintf("Unknown[%x]", int(cmd))
 }
 
-var ethGenlCmdMsgs = map[ethGenlCmd]string{
-	ETHTOOL_MSG_USER_NONE:          "",
-	ETHTOOL_MSG_STRSET_GET:         "-k",
-	ETHTOOL_MSG_LINKINFO_GET:       "<default>",
-	ETHTOOL_MSG_LINKINFO_SET:       "",
-	ETHTOOL_MSG_LINKMODES_GET:      "<default>",
-	ETHTOOL_MSG_LINKMODES_SET:      "",
-	ETHTOOL_MSG_LINKSTATE_GET:      "<default>",
-	ETHTOOL_MSG_DEBUG_GET:          "<default>",
-	ETHTOOL_MSG_DEBUG_SET:          "",
-	ETHTOOL_MSG_WOL_GET:            "<default>",
-	ETHTOOL_MSG_WOL_SET:            "",
-	ETHTOOL_MSG_FEATURES_GET:       "-k",
-	ETHTOOL_MSG_FEATURES_SET:       "-K",
-	ETHTOOL_MSG_PRIVFLAGS_GET:      "--show-priv-flags",
-	ETHTOOL_MSG_PRIVFLAGS_SET:      "",
-	ETHTOOL_MSG_RINGS_GET:          "-g",
-	ETHTOOL_MSG_RINGS_SET:          "-G",
-	ETHTOOL_MSG_CHANNELS_GET:       "-l",
-	ETHTOOL_MSG_CHANNELS_SET:       "-L",
-	ETHTOOL_MSG_COALESCE_GET:       "-c",
-	ETHTOOL_MSG_COALESCE_SET:       "-C",
-	ETHTOOL_MSG_PAUSE_GET:          "-a",
-	ETHTOOL_MSG_PAUSE_SET:          "-A",
-	ETHTOOL_MSG_EEE_GET:            "--show-eee",
-	ETHTOOL_MSG_EEE_SET:            "--set-eee",
-	ETHTOOL_MSG_TSINFO_GET:         "-T",
-	ETHTOOL_MSG_CABLE_TEST_ACT:     "",
-	ETHTOOL_MSG_CABLE_TEST_TDR_ACT: "",
-	ETHTOOL_MSG_TUNNEL_INFO_GET:    "",
-	ETHTOOL_MSG_FEC_GET:            "--show-fec",
-	ETHTOOL_MSG_FEC_SET:            "--set-fec",
-	ETHTOOL_MSG_MODULE_EEPROM_GET:  "-m",
-	ETHTOOL_MSG_STATS_GET:          "",
-	ETHTOOL_MSG_PHC_VCLOCKS_GET:    "",
-	ETHTOOL_MSG_MODULE_GET:         "",
-	ETHTOOL_MSG_MODULE_SET:         "",
-	ETHTOOL_MSG_PSE_GET:            "",
-	ETHTOOL_MSG_PSE_SET:            "",
-	ETHTOOL_MSG_RSS_GET:            "",
-	ETHTOOL_MSG_PLCA_GET_CFG:       "",
-	ETHTOOL_MSG_PLCA_SET_CFG:       "",
-	ETHTOOL_MSG_PLCA_GET_STATUS:    "",
-	ETHTOOL_MSG_MM_GET:             "",
-	ETHTOOL_MSG_MM_SET:             "",
+var genlCmdMsgs = map[GenlCmd]string{
+	ETHTOOL_MSG_USER_NONE:           "",
+	ETHTOOL_MSG_STRSET_GET:          "-k",
+	ETHTOOL_MSG_LINKINFO_GET:        "<default>",
+	ETHTOOL_MSG_LINKINFO_SET:        "-s",
+	ETHTOOL_MSG_LINKMODES_GET:       "<default>",
+	ETHTOOL_MSG_LINKMODES_SET:       "-s",
+	ETHTOOL_MSG_LINKSTATE_GET:       "<default>",
+	ETHTOOL_MSG_DEBUG_GET:           "<default>",
+	ETHTOOL_MSG_DEBUG_SET:           "",
+	ETHTOOL_MSG_WOL_GET:             "<default>",
+	ETHTOOL_MSG_WOL_SET:             "",
+	ETHTOOL_MSG_FEATURES_GET:        "-k",
+	ETHTOOL_MSG_FEATURES_SET:        "-K",
+	ETHTOOL_MSG_PRIVFLAGS_GET:       "--show-priv-flags",
+	ETHTOOL_MSG_PRIVFLAGS_SET:       "",
+	ETHTOOL_MSG_RINGS_GET:           "-g",
+	ETHTOOL_MSG_RINGS_SET:           "-G",
+	ETHTOOL_MSG_CHANNELS_GET:        "-l",
+	ETHTOOL_MSG_CHANNELS_SET:        "-L",
+	ETHTOOL_MSG_COALESCE_GET:        "-c",
+	ETHTOOL_MSG_COALESCE_SET:        "-C",
+	ETHTOOL_MSG_PAUSE_GET:           "-a",
+	ETHTOOL_MSG_PAUSE_SET:           "-A",
+	ETHTOOL_MSG_EEE_GET:             "--show-eee",
+	ETHTOOL_MSG_EEE_SET:             "--set-eee",
+	ETHTOOL_MSG_TSINFO_GET:          "-T",
+	ETHTOOL_MSG_CABLE_TEST_ACT:      "",
+	ETHTOOL_MSG_CABLE_TEST_TDR_ACT:  "",
+	ETHTOOL_MSG_TUNNEL_INFO_GET:     "",
+	ETHTOOL_MSG_FEC_GET:             "--show-fec",
+	ETHTOOL_MSG_FEC_SET:             "--set-fec",
+	ETHTOOL_MSG_MODULE_EEPROM_GET:   "-m",
+	ETHTOOL_MSG_STATS_GET:           "",
+	ETHTOOL_MSG_PHC_VCLOCKS_GET:     "",
+	ETHTOOL_MSG_MODULE_GET:          "",
+	ETHTOOL_MSG_MODULE_SET:          "",
+	ETHTOOL_MSG_PSE_GET:             "",
+	ETHTOOL_MSG_PSE_SET:             "",
+	ETHTOOL_MSG_RSS_GET:             "-x",
+	ETHTOOL_MSG_PLCA_GET_CFG:        "",
+	ETHTOOL_MSG_PLCA_SET_CFG:        "",
+	ETHTOOL_MSG_PLCA_GET_STATUS:     "",
+	ETHTOOL_MSG_MM_GET:              "",
+	ETHTOOL_MSG_MM_SET:              "",
+	ETHTOOL_MSG_MODULE_FW_FLASH_ACT: "--flash-module-firmware",
+	ETHTOOL_MSG_PHY_GET:             "",
+	ETHTOOL_MSG_TSCONFIG_GET:        "",
+	ETHTOOL_MSG_TSCONFIG_SET:        "",
+	ETHTOOL_MSG_RSS_SET:             "-X",
+	ETHTOOL_MSG_RSS_CREATE_ACT:      "-X",
+	ETHTOOL_MSG_RSS_DELETE_ACT:      "-X",
 }
 
 func init() {
-	for k, v := range ethGenlCmdMsgs {
+	for k, v := range genlCmdMsgs {
 		if v == "" {
 			continue
 		}
 
 		var msgs []string
 		for _, msg := range strings.Split(v, ",") {
-			if m := ethtoolOptions[msg]; m != "" {
+			if m := options[msg]; m != "" {
 				msgs = append(msgs, m)
 			}
 		}
 
 		if len(msgs) > 0 {
-			ethGenlCmdMsgs[k] = strings.Join(msgs, ", ")
+			genlCmdMsgs[k] = strings.Join(msgs, ", ")
 		}
 	}
 }
 
-func (cmd ethGenlCmd) Message() string {
-	return ethGenlCmdMsgs[cmd]
+// Message returns the ethtool options which may send the message.
+func (cmd GenlCmd) Message() string {
+	return genlCmdMsgs[cmd]
 }
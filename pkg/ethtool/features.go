@@ -0,0 +1,316 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// From include/uapi/linux/ethtool.h and include/uapi/linux/ethtool_netlink.h
+const (
+	ethSsFeatures  = 4 // ETH_SS_FEATURES
+	ethSsLinkModes = 9 // ETH_SS_LINK_MODES
+	ethGstringLen  = 32
+
+	sizeofEthtoolValue = 8
+
+	// The blocks of struct ethtool_gfeatures and struct ethtool_sfeatures
+	// follow cmd and size.
+	featuresBlocks         = 8
+	sizeofGetFeaturesBlock = 16
+	sizeofSetFeaturesBlock = 8
+
+	ETHTOOL_A_FEATURES_WANTED = 3
+
+	ETHTOOL_A_BITSET_NOMASK = 1
+	ETHTOOL_A_BITSET_SIZE   = 2
+	ETHTOOL_A_BITSET_BITS   = 3
+	ETHTOOL_A_BITSET_VALUE  = 4
+	ETHTOOL_A_BITSET_MASK   = 5
+
+	ETHTOOL_A_BITSET_BIT_INDEX = 1
+	ETHTOOL_A_BITSET_BIT_NAME  = 2
+	ETHTOOL_A_BITSET_BIT_VALUE = 3
+)
+
+// ifreqData is struct ifreq with ifr_data.
+type ifreqData struct {
+	name [unix.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [16]byte
+}
+
+func ioctlEthtool(fd int, ifname string, data []byte) error {
+	var ifr ifreqData
+	copy(ifr.name[:], ifname)
+	ifr.data = unsafe.Pointer(&data[0])
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// stringSet reads the string set of the kernel by ETHTOOL_GSSET_INFO and
+// ETHTOOL_GSTRINGS on the loopback device, which works for the string sets
+// that are the same for all the devices.
+func stringSet(set uint32) ([]string, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	// struct ethtool_sset_info with one u32 of data
+	info := make([]byte, 20)
+	putU32(info, 0, ETHTOOL_GSSET_INFO)
+	putU64(info, 8, 1<<set)
+	if err := ioctlEthtool(fd, "lo", info); err != nil {
+		return nil, fmt.Errorf("failed to get the count of the string set %d: %w", set, err)
+	}
+	if u64At(info, 8) == 0 {
+		return nil, fmt.Errorf("no string set %d", set)
+	}
+	count := int(u32At(info, 16))
+
+	// struct ethtool_gstrings
+	gstrings := make([]byte, 12+count*ethGstringLen)
+	putU32(gstrings, 0, ETHTOOL_GSTRINGS)
+	putU32(gstrings, 4, set)
+	putU32(gstrings, 8, uint32(count))
+	if err := ioctlEthtool(fd, "lo", gstrings); err != nil {
+		return nil, fmt.Errorf("failed to get the strings of the string set %d: %w", set, err)
+	}
+
+	names := make([]string, count)
+	for i := range names {
+		names[i] = cString(gstrings[12+i*ethGstringLen:][:ethGstringLen])
+	}
+
+	return names, nil
+}
+
+// FeatureNames reads the names of the netdev features by their bits, i.e.
+// the ETH_SS_FEATURES string set of the kernel.
+func FeatureNames() ([]string, error) {
+	return stringSet(ethSsFeatures)
+}
+
+// legacyFeatures are the names of ethtool -K for the features of the legacy
+// ETHTOOL_[GS]* commands passing struct ethtool_value.
+var legacyFeatures = map[IoctlCmd]string{
+	ETHTOOL_GRXCSUM: "rx",
+	ETHTOOL_SRXCSUM: "rx",
+	ETHTOOL_GTXCSUM: "tx",
+	ETHTOOL_STXCSUM: "tx",
+	ETHTOOL_GSG:     "sg",
+	ETHTOOL_SSG:     "sg",
+	ETHTOOL_GTSO:    "tso",
+	ETHTOOL_STSO:    "tso",
+	ETHTOOL_GUFO:    "ufo",
+	ETHTOOL_SUFO:    "ufo",
+	ETHTOOL_GGSO:    "gso",
+	ETHTOOL_SGSO:    "gso",
+	ETHTOOL_GGRO:    "gro",
+	ETHTOOL_SGRO:    "gro",
+}
+
+// ethFlags are the names of ethtool -K for the ETH_FLAG_* bits of
+// ETHTOOL_GFLAGS and ETHTOOL_SFLAGS.
+var ethFlags = []struct {
+	bit  uint32
+	name string
+}{
+	{1 << 15, "lro"},    // ETH_FLAG_LRO
+	{1 << 8, "rxvlan"},  // ETH_FLAG_RXVLAN
+	{1 << 7, "txvlan"},  // ETH_FLAG_TXVLAN
+	{1 << 27, "ntuple"}, // ETH_FLAG_NTUPLE
+	{1 << 28, "rxhash"}, // ETH_FLAG_RXHASH
+}
+
+// value renders struct ethtool_value of the legacy feature commands, e.g.
+// "gro off".
+func (d *Decoder) value(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofEthtoolValue {
+		return ""
+	}
+
+	return legacyFeatures[cmd] + " " + onOff(u32At(data, 4) != 0)
+}
+
+// flags renders struct ethtool_value of ETHTOOL_GFLAGS and ETHTOOL_SFLAGS,
+// e.g. "lro off rxvlan on txvlan on ntuple off rxhash on".
+func (d *Decoder) flags(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofEthtoolValue {
+		return ""
+	}
+
+	flags := u32At(data, 4)
+	parts := make([]string, 0, len(ethFlags))
+	for _, f := range ethFlags {
+		parts = append(parts, f.name+" "+onOff(flags&f.bit != 0))
+		flags &^= f.bit
+	}
+	if flags != 0 {
+		parts = append(parts, fmt.Sprintf("0x%x on", flags))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// sfeatures renders the features changed by struct ethtool_sfeatures, e.g.
+// "rx-gro-hw on tx-tcp-segmentation off".
+func (d *Decoder) sfeatures(cmd IoctlCmd, data []byte) string {
+	if len(data) < featuresBlocks {
+		return ""
+	}
+
+	var parts []string
+	blocks := data[featuresBlocks:]
+	for i := 0; i < int(u32At(data, 4)) && len(blocks) >= sizeofSetFeaturesBlock; i++ {
+		valid, requested := u32At(blocks, 0), u32At(blocks, 4)
+		for bit := 0; bit < 32; bit++ {
+			if valid&(1<<bit) != 0 {
+				parts = append(parts, d.feature(i*32+bit)+" "+onOff(requested&(1<<bit) != 0))
+			}
+		}
+		blocks = blocks[sizeofSetFeaturesBlock:]
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// gfeatures renders the active features of struct ethtool_gfeatures, e.g.
+// "active: rx-checksum tx-scatter-gather".
+func (d *Decoder) gfeatures(cmd IoctlCmd, data []byte) string {
+	if len(data) < featuresBlocks {
+		return ""
+	}
+
+	var names []string
+	blocks := data[featuresBlocks:]
+	for i := 0; i < int(u32At(data, 4)) && len(blocks) >= sizeofGetFeaturesBlock; i++ {
+		// available, requested, active and never_changed
+		active := u32At(blocks, 8)
+		for bit := 0; bit < 32; bit++ {
+			if active&(1<<bit) != 0 {
+				names = append(names, d.feature(i*32+bit))
+			}
+		}
+		blocks = blocks[sizeofGetFeaturesBlock:]
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	return "active: " + strings.Join(names, " ")
+}
+
+// featuresMsg renders the wanted features of ETHTOOL_MSG_FEATURES_SET, e.g.
+// "rx-gro-hw on tx-tcp-segmentation off".
+func (d *Decoder) featuresMsg(cmd GenlCmd, data []byte) string {
+	for _, attr := range parseAttrs(data) {
+		if attr.typ == ETHTOOL_A_FEATURES_WANTED {
+			return bitset(attr.val, d.feature)
+		}
+	}
+
+	return ""
+}
+
+// bit is a bit of the netlink bitset, by its name.
+type bit struct {
+	name string
+	on   bool
+}
+
+// parseBitset parses the bits of the netlink bitset, e.g. of the features, in
+// either the compact form of value and mask, or the verbose form of the bits,
+// by the names of the bits. Without the mask, the value is the whole set, of
+// which only the bits on are returned.
+func parseBitset(data []byte, name func(bit int) string) []bit {
+	var (
+		bits        []bit
+		nomask      bool
+		value, mask []byte
+		verboseBits []byte
+	)
+	for _, attr := range parseAttrs(data) {
+		switch attr.typ {
+		case ETHTOOL_A_BITSET_NOMASK:
+			nomask = true
+		case ETHTOOL_A_BITSET_BITS:
+			verboseBits = attr.val
+		case ETHTOOL_A_BITSET_VALUE:
+			value = attr.val
+		case ETHTOOL_A_BITSET_MASK:
+			mask = attr.val
+		}
+	}
+
+	if verboseBits != nil {
+		for _, b := range parseAttrs(verboseBits) {
+			var (
+				bitName string
+				on      = nomask
+			)
+			for _, attr := range parseAttrs(b.val) {
+				switch attr.typ {
+				case ETHTOOL_A_BITSET_BIT_INDEX:
+					if bitName == "" && len(attr.val) >= 4 {
+						bitName = name(int(u32At(attr.val, 0)))
+					}
+				case ETHTOOL_A_BITSET_BIT_NAME:
+					bitName = cString(attr.val)
+				case ETHTOOL_A_BITSET_BIT_VALUE:
+					on = true
+				}
+			}
+			if bitName != "" {
+				bits = append(bits, bit{bitName, on})
+			}
+		}
+
+		return bits
+	}
+
+	if nomask {
+		mask = nil
+	}
+	for i := 0; i+4 <= len(value); i += 4 {
+		v := u32At(value, i)
+		m := ^uint32(0)
+		if mask != nil {
+			if i+4 > len(mask) {
+				break
+			}
+			m = u32At(mask, i)
+		}
+		for n := 0; n < 32; n++ {
+			if m&(1<<n) != 0 && (mask != nil || v&(1<<n) != 0) {
+				bits = append(bits, bit{name(i*8 + n), v&(1<<n) != 0})
+			}
+		}
+	}
+
+	return bits
+}
+
+// bitset renders the bits of the netlink bitset in the style of ethtool, e.g.
+// "rx-gro-hw on tx-tcp-segmentation off".
+func bitset(data []byte, name func(bit int) string) string {
+	bits := parseBitset(data, name)
+	parts := make([]string, 0, len(bits))
+	for _, b := range bits {
+		parts = append(parts, b.name+" "+onOff(b.on))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+// bitAttr builds the ETHTOOL_A_BITSET_BITS_BIT attribute of the verbose
+// bitset.
+func bitAttr(index uint32, name string, on bool) []byte {
+	attrs := [][]byte{attrU32(ETHTOOL_A_BITSET_BIT_INDEX, index)}
+	if name != "" {
+		attrs = append(attrs, attrString(ETHTOOL_A_BITSET_BIT_NAME, name))
+	}
+	if on {
+		attrs = append(attrs, attr(ETHTOOL_A_BITSET_BIT_VALUE, nil))
+	}
+
+	return nested(1, attrs...)
+}
+
+func TestBitset(t *testing.T) {
+	names := []string{"tx-scatter-gather", "tx-checksum-ipv4", "rx-gro-hw", "rx-checksum"}
+	name := func(bit int) string {
+		if bit < len(names) {
+			return names[bit]
+		}
+		return "bit"
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			// ethtool -K eth0 rx-gro-hw on tx-checksum-ipv4 off
+			name: "compact",
+			data: concat(
+				attrU32(ETHTOOL_A_BITSET_SIZE, 4),
+				attrU32(ETHTOOL_A_BITSET_VALUE, 1<<2),
+				attrU32(ETHTOOL_A_BITSET_MASK, 1<<1|1<<2),
+			),
+			want: "tx-checksum-ipv4 off rx-gro-hw on",
+		},
+		{
+			name: "compact nomask",
+			data: concat(
+				attr(ETHTOOL_A_BITSET_NOMASK, nil),
+				attrU32(ETHTOOL_A_BITSET_SIZE, 4),
+				attrU32(ETHTOOL_A_BITSET_VALUE, 1<<0|1<<3),
+			),
+			want: "tx-scatter-gather on rx-checksum on",
+		},
+		{
+			name: "compact second word",
+			data: concat(
+				attrU32(ETHTOOL_A_BITSET_SIZE, 36),
+				attr(ETHTOOL_A_BITSET_VALUE, u32Bytes(0, 1<<1)),
+				attr(ETHTOOL_A_BITSET_MASK, u32Bytes(0, 1<<1)),
+			),
+			want: "bit on",
+		},
+		{
+			// ethtool -K eth0 rx-gro-hw on tx-checksum-ipv4 off, without
+			// ETHTOOL_FLAG_COMPACT_BITSETS
+			name: "verbose",
+			data: nested(ETHTOOL_A_BITSET_BITS,
+				bitAttr(2, "rx-gro-hw", true),
+				bitAttr(1, "tx-checksum-ipv4", false),
+			),
+			want: "rx-gro-hw on tx-checksum-ipv4 off",
+		},
+		{
+			name: "verbose by index",
+			data: nested(ETHTOOL_A_BITSET_BITS,
+				bitAttr(3, "", true),
+			),
+			want: "rx-checksum on",
+		},
+		{
+			name: "verbose nomask",
+			data: concat(
+				attr(ETHTOOL_A_BITSET_NOMASK, nil),
+				nested(ETHTOOL_A_BITSET_BITS, bitAttr(0, "tx-scatter-gather", false)),
+			),
+			want: "tx-scatter-gather on",
+		},
+		{
+			name: "empty",
+			data: nil,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bitset(tt.data, name); got != tt.want {
+				t.Errorf("bitset() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	d := &Decoder{Features: []string{"tx-scatter-gather", "tx-checksum-ipv4"}}
+
+	sfeatures := u32Bytes(uint32(ETHTOOL_SFEATURES), 2, 1<<0|1<<1, 1<<0, 1<<2, 0)
+	gfeatures := u32Bytes(uint32(ETHTOOL_GFEATURES), 1, 0x3, 0x3, 1<<1, 0)
+
+	testIoctl(t, d, []ioctlTest{
+		{"sfeatures", ETHTOOL_SFEATURES, sfeatures, "tx-scatter-gather on tx-checksum-ipv4 off feature-34 off"},
+		{"gfeatures", ETHTOOL_GFEATURES, gfeatures, "active: tx-checksum-ipv4"},
+		{"gro off", ETHTOOL_SGRO, u32Bytes(uint32(ETHTOOL_SGRO), 0), "gro off"},
+		{"tso on", ETHTOOL_STSO, u32Bytes(uint32(ETHTOOL_STSO), 1), "tso on"},
+		{"flags", ETHTOOL_SFLAGS, u32Bytes(uint32(ETHTOOL_SFLAGS), 1<<8|1<<28), "lro off rxvlan on txvlan off ntuple off rxhash on"},
+		{"unknown flags", ETHTOOL_SFLAGS, u32Bytes(uint32(ETHTOOL_SFLAGS), 1<<0), "lro off rxvlan off txvlan off ntuple off rxhash off 0x1 on"},
+		{"short", ETHTOOL_SGRO, u32Bytes(uint32(ETHTOOL_SGRO)), ""},
+		{"undecoded", ETHTOOL_GDRVINFO, u32Bytes(uint32(ETHTOOL_GDRVINFO), 0), ""},
+	})
+
+	testGenl(t, d, []genlTest{
+		{
+			name: "features msg",
+			cmd:  ETHTOOL_MSG_FEATURES_SET,
+			data: concat(
+				nested(ETHTOOL_A_REQUEST_HEADER, attrString(ETHTOOL_A_HEADER_DEV_NAME, "eth0")),
+				nested(ETHTOOL_A_FEATURES_WANTED,
+					attrU32(ETHTOOL_A_BITSET_SIZE, 2),
+					attrU32(ETHTOOL_A_BITSET_VALUE, 1<<1),
+					attrU32(ETHTOOL_A_BITSET_MASK, 1<<1),
+				),
+			),
+			want: "tx-checksum-ipv4 on",
+		},
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// From include/uapi/linux/ethtool.h and include/uapi/linux/ethtool_netlink.h
+const (
+	// The offsets in struct ethtool_flash.
+	flashRegion = 4
+	flashData   = 8
+	sizeofFlash = 136
+
+	ETHTOOL_A_MODULE_FW_FLASH_FILE_NAME  = 2
+	ETHTOOL_A_MODULE_FW_FLASH_PASSWORD   = 3
+	ETHTOOL_A_MODULE_FW_FLASH_STATUS     = 4
+	ETHTOOL_A_MODULE_FW_FLASH_STATUS_MSG = 5
+	ETHTOOL_A_MODULE_FW_FLASH_DONE       = 6
+	ETHTOOL_A_MODULE_FW_FLASH_TOTAL      = 7
+
+	ETHTOOL_MODULE_FW_FLASH_STATUS_STARTED     = 1
+	ETHTOOL_MODULE_FW_FLASH_STATUS_IN_PROGRESS = 2
+	ETHTOOL_MODULE_FW_FLASH_STATUS_COMPLETED   = 3
+	ETHTOOL_MODULE_FW_FLASH_STATUS_ERROR       = 4
+)
+
+// uintAttr returns the value of the NLA_UINT attribute, which is either u32
+// or u64.
+func uintAttr(b []byte) uint64 {
+	switch {
+	case len(b) >= 8:
+		return u64At(b, 0)
+	case len(b) >= 4:
+		return uint64(u32At(b, 0))
+	default:
+		return 0
+	}
+}
+
+// flashdev renders struct ethtool_flash of ETHTOOL_FLASHDEV, e.g.
+// "file fw.bin region 1". The region 0 is all the regions.
+func (d *Decoder) flashdev(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofFlash {
+		return ""
+	}
+
+	s := "file " + cString(data[flashData:sizeofFlash])
+	if region := u32At(data, flashRegion); region != 0 {
+		s += fmt.Sprintf(" region %d", region)
+	}
+
+	return s
+}
+
+// moduleFlash renders ETHTOOL_MSG_MODULE_FW_FLASH_ACT, e.g. "file fw.bin
+// password set". The password is never shown.
+func (d *Decoder) moduleFlash(cmd GenlCmd, data []byte) string {
+	var parts []string
+	for _, attr := range parseAttrs(data) {
+		switch attr.typ {
+		case ETHTOOL_A_MODULE_FW_FLASH_FILE_NAME:
+			parts = append(parts, "file "+cString(attr.val))
+		case ETHTOOL_A_MODULE_FW_FLASH_PASSWORD:
+			parts = append(parts, "password set")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+var moduleFlashStatuses = []string{
+	"",
+	"started",
+	"in progress",
+	"completed",
+	"error",
+}
+
+// moduleFlashStatus returns the ETHTOOL_MODULE_FW_FLASH_STATUS_* status of
+// ETHTOOL_MSG_MODULE_FW_FLASH_NTF, or 0 if it is missing.
+func moduleFlashStatus(data []byte) uint32 {
+	for _, attr := range parseAttrs(data) {
+		if attr.typ == ETHTOOL_A_MODULE_FW_FLASH_STATUS && len(attr.val) >= 4 {
+			return u32At(attr.val, 0)
+		}
+	}
+
+	return 0
+}
+
+// ModuleFlashEnded reports whether the attributes of
+// ETHTOOL_MSG_MODULE_FW_FLASH_NTF tell the flash has completed or failed.
+func ModuleFlashEnded(data []byte) bool {
+	status := moduleFlashStatus(data)
+	return status == ETHTOOL_MODULE_FW_FLASH_STATUS_COMPLETED || status == ETHTOOL_MODULE_FW_FLASH_STATUS_ERROR
+}
+
+// moduleFlashNtf renders the progress of the module firmware flash, e.g.
+// "in progress 1024/65536" or "error: Module is not responsive".
+func (d *Decoder) moduleFlashNtf(cmd NotifyCmd, data []byte) string {
+	var (
+		status      uint32
+		msg         string
+		done, total uint64
+	)
+	for _, attr := range parseAttrs(data) {
+		switch attr.typ {
+		case ETHTOOL_A_MODULE_FW_FLASH_STATUS:
+			if len(attr.val) >= 4 {
+				status = u32At(attr.val, 0)
+			}
+		case ETHTOOL_A_MODULE_FW_FLASH_STATUS_MSG:
+			msg = cString(attr.val)
+		case ETHTOOL_A_MODULE_FW_FLASH_DONE:
+			done = uintAttr(attr.val)
+		case ETHTOOL_A_MODULE_FW_FLASH_TOTAL:
+			total = uintAttr(attr.val)
+		}
+	}
+
+	var s string
+	if int(status) < len(moduleFlashStatuses) && status != 0 {
+		s = moduleFlashStatuses[status]
+	} else {
+		s = fmt.Sprintf("status %d", status)
+	}
+	if status == ETHTOOL_MODULE_FW_FLASH_STATUS_IN_PROGRESS && total != 0 {
+		s += fmt.Sprintf(" %d/%d", done, total)
+	}
+	if msg != "" {
+		s += ": " + msg
+	}
+
+	return s
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+func TestFlashdev(t *testing.T) {
+	// struct ethtool_flash of ethtool -f eth0 fw.bin 1
+	flash := make([]byte, sizeofFlash)
+	putU32(flash, 0, uint32(ETHTOOL_FLASHDEV))
+	putU32(flash, flashRegion, 1)
+	copy(flash[flashData:], "fw.bin")
+
+	all := make([]byte, sizeofFlash)
+	putU32(all, 0, uint32(ETHTOOL_FLASHDEV))
+	copy(all[flashData:], "fw.bin")
+
+	testIoctl(t, new(Decoder), []ioctlTest{
+		{"region", ETHTOOL_FLASHDEV, flash, "file fw.bin region 1"},
+		{"all regions", ETHTOOL_FLASHDEV, all, "file fw.bin"},
+		{"short", ETHTOOL_FLASHDEV, flash[:sizeofFlash-1], ""},
+	})
+}
+
+// The password is never shown, whether the secrets are shown or not.
+func TestModuleFlash(t *testing.T) {
+	for _, d := range []*Decoder{{}, {ShowSecrets: true}} {
+		testGenl(t, d, []genlTest{
+			{
+				name: "password",
+				cmd:  ETHTOOL_MSG_MODULE_FW_FLASH_ACT,
+				data: concat(
+					attrString(ETHTOOL_A_MODULE_FW_FLASH_FILE_NAME, "fw.bin"),
+					attrU32(ETHTOOL_A_MODULE_FW_FLASH_PASSWORD, 0x12345678),
+				),
+				want: "file fw.bin password set",
+			},
+			{
+				name: "no password",
+				cmd:  ETHTOOL_MSG_MODULE_FW_FLASH_ACT,
+				data: attrString(ETHTOOL_A_MODULE_FW_FLASH_FILE_NAME, "fw.bin"),
+				want: "file fw.bin",
+			},
+		})
+	}
+}
+
+func TestModuleFlashNtf(t *testing.T) {
+	testNotify(t, new(Decoder), []notifyTest{
+		{
+			name: "started",
+			cmd:  ETHTOOL_MSG_MODULE_FW_FLASH_NTF,
+			data: attrU32(ETHTOOL_A_MODULE_FW_FLASH_STATUS, ETHTOOL_MODULE_FW_FLASH_STATUS_STARTED),
+			want: "started",
+		},
+		{
+			name: "in progress u32",
+			cmd:  ETHTOOL_MSG_MODULE_FW_FLASH_NTF,
+			data: concat(
+				attrU32(ETHTOOL_A_MODULE_FW_FLASH_STATUS, ETHTOOL_MODULE_FW_FLASH_STATUS_IN_PROGRESS),
+				attrU32(ETHTOOL_A_MODULE_FW_FLASH_DONE, 1024),
+				attrU32(ETHTOOL_A_MODULE_FW_FLASH_TOTAL, 65536),
+			),
+			want: "in progress 1024/65536",
+		},
+		{
+			name: "in progress u64",
+			cmd:  ETHTOOL_MSG_MODULE_FW_FLASH_NTF,
+			data: concat(
+				attrU32(ETHTOOL_A_MODULE_FW_FLASH_STATUS, ETHTOOL_MODULE_FW_FLASH_STATUS_IN_PROGRESS),
+				attrU64(ETHTOOL_A_MODULE_FW_FLASH_DONE, 1<<32),
+				attrU64(ETHTOOL_A_MODULE_FW_FLASH_TOTAL, 1<<33),
+			),
+			want: "in progress 4294967296/8589934592",
+		},
+		{
+			name: "error",
+			cmd:  ETHTOOL_MSG_MODULE_FW_FLASH_NTF,
+			data: concat(
+				attrU32(ETHTOOL_A_MODULE_FW_FLASH_STATUS, ETHTOOL_MODULE_FW_FLASH_STATUS_ERROR),
+				attrString(ETHTOOL_A_MODULE_FW_FLASH_STATUS_MSG, "Module is not responsive"),
+			),
+			want: "error: Module is not responsive",
+		},
+		{
+			name: "unknown status",
+			cmd:  ETHTOOL_MSG_MODULE_FW_FLASH_NTF,
+			data: attrU32(ETHTOOL_A_MODULE_FW_FLASH_STATUS, 9),
+			want: "status 9",
+		},
+	})
+}
+
+func TestModuleFlashEnded(t *testing.T) {
+	for _, tt := range []struct {
+		status uint32
+		ended  bool
+	}{
+		{ETHTOOL_MODULE_FW_FLASH_STATUS_STARTED, false},
+		{ETHTOOL_MODULE_FW_FLASH_STATUS_IN_PROGRESS, false},
+		{ETHTOOL_MODULE_FW_FLASH_STATUS_COMPLETED, true},
+		{ETHTOOL_MODULE_FW_FLASH_STATUS_ERROR, true},
+	} {
+		data := attrU32(ETHTOOL_A_MODULE_FW_FLASH_STATUS, tt.status)
+		if got := ModuleFlashEnded(data); got != tt.ended {
+			t.Errorf("ModuleFlashEnded(status %d) = %v, want %v", tt.status, got, tt.ended)
+		}
+	}
+
+	if ModuleFlashEnded(nil) {
+		t.Errorf("ModuleFlashEnded() without status = true, want false")
+	}
+}
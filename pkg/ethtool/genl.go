@@ -0,0 +1,128 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// GenlName is the name of the ethtool genetlink family.
+	GenlName = "ethtool"
+
+	sizeofGenlmsghdr = 4
+
+	nlaTypeMask = ^uint16(unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER)
+)
+
+func nlAlign(n int) int {
+	return (n + unix.NLA_ALIGNTO - 1) & ^(unix.NLA_ALIGNTO - 1)
+}
+
+// nlattr is a netlink attribute, of which val may be truncated from the len
+// bytes of the payload.
+type nlattr struct {
+	typ uint16
+	len int
+	val []byte
+}
+
+// parseAttrs parses the netlink attributes, the last of which may be
+// truncated.
+func parseAttrs(data []byte) []nlattr {
+	var attrs []nlattr
+	for len(data) >= unix.SizeofNlAttr {
+		n := int(binary.NativeEndian.Uint16(data))
+		if n < unix.SizeofNlAttr {
+			break
+		}
+
+		attrs = append(attrs, nlattr{
+			typ: binary.NativeEndian.Uint16(data[2:]) & nlaTypeMask,
+			len: n - unix.SizeofNlAttr,
+			val: data[unix.SizeofNlAttr:min(n, len(data))],
+		})
+
+		if nlAlign(n) >= len(data) {
+			break
+		}
+		data = data[nlAlign(n):]
+	}
+
+	return attrs
+}
+
+// FamilyID resolves the id of the ethtool genetlink family.
+func FamilyID() (uint16, error) {
+	return genlFamilyID(GenlName)
+}
+
+// genlFamilyID resolves the id of the generic netlink family by name.
+func genlFamilyID(name string) (uint16, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_GENERIC)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create genetlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	attrLen := unix.SizeofNlAttr + len(name) + 1
+	msgLen := unix.NLMSG_HDRLEN + sizeofGenlmsghdr + nlAlign(attrLen)
+	msg := make([]byte, msgLen)
+
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(msgLen))
+	binary.NativeEndian.PutUint16(msg[4:6], unix.GENL_ID_CTRL)
+	binary.NativeEndian.PutUint16(msg[6:8], unix.NLM_F_REQUEST)
+	binary.NativeEndian.PutUint32(msg[8:12], 1)
+	msg[unix.NLMSG_HDRLEN] = unix.CTRL_CMD_GETFAMILY
+	msg[unix.NLMSG_HDRLEN+1] = 1
+
+	attr := msg[unix.NLMSG_HDRLEN+sizeofGenlmsghdr:]
+	binary.NativeEndian.PutUint16(attr[0:2], uint16(attrLen))
+	binary.NativeEndian.PutUint16(attr[2:4], unix.CTRL_ATTR_FAMILY_NAME)
+	copy(attr[unix.SizeofNlAttr:], name)
+
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return 0, fmt.Errorf("failed to send genetlink request: %w", err)
+	}
+
+	buf := make([]byte, 8192)
+	n, _, err := unix.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to receive genetlink reply: %w", err)
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse genetlink reply: %w", err)
+	}
+
+	for _, m := range msgs {
+		switch m.Header.Type {
+		case unix.NLMSG_ERROR:
+			if len(m.Data) >= 4 {
+				if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return 0, unix.Errno(-errno)
+				}
+			}
+
+		case unix.GENL_ID_CTRL:
+			if len(m.Data) < sizeofGenlmsghdr {
+				continue
+			}
+
+			for _, attr := range parseAttrs(m.Data[sizeofGenlmsghdr:]) {
+				if attr.typ == unix.CTRL_ATTR_FAMILY_ID && len(attr.val) >= 2 {
+					return binary.NativeEndian.Uint16(attr.val), nil
+				}
+			}
+		}
+	}
+
+	return 0, errors.New("no family id in genetlink reply")
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+// From include/uapi/linux/ethtool_netlink.h
+const (
+	// ETHTOOL_A_REQUEST_HEADER is the ETHTOOL_A_*_HEADER attribute of all
+	// the messages.
+	ETHTOOL_A_REQUEST_HEADER = 1
+
+	ETHTOOL_A_HEADER_DEV_INDEX = 1
+	ETHTOOL_A_HEADER_DEV_NAME  = 2
+	ETHTOOL_A_HEADER_FLAGS     = 3
+
+	ETHTOOL_FLAG_COMPACT_BITSETS = 1 << 0
+	ETHTOOL_FLAG_OMIT_REPLY      = 1 << 1
+	ETHTOOL_FLAG_STATS           = 1 << 2
+)
+
+// headerFlags are the ETHTOOL_FLAG_* flags by their bits.
+var headerFlags = []string{"compact-bitsets", "omit-reply", "stats"}
+
+// Header is the request header of the ethtool genetlink message.
+type Header struct {
+	// DevIndex is the ifindex identifying the netdev, 0 if missing.
+	DevIndex uint32
+	// DevName is the name identifying the netdev, which may be an
+	// alternative name, empty if missing.
+	DevName string
+	// HasDevName reports whether ETHTOOL_A_HEADER_DEV_NAME is present.
+	HasDevName bool
+	// Flags are the ETHTOOL_FLAG_* flags.
+	Flags uint32
+}
+
+// ParseHeader parses the request header in the attributes of the genetlink
+// message. It returns false if there is no header.
+func ParseHeader(data []byte) (Header, bool) {
+	var h Header
+	for _, attr := range parseAttrs(data) {
+		if attr.typ != ETHTOOL_A_REQUEST_HEADER {
+			continue
+		}
+
+		for _, attr := range parseAttrs(attr.val) {
+			switch attr.typ {
+			case ETHTOOL_A_HEADER_DEV_INDEX:
+				if len(attr.val) >= 4 {
+					h.DevIndex = u32At(attr.val, 0)
+				}
+			case ETHTOOL_A_HEADER_DEV_NAME:
+				h.DevName, h.HasDevName = cString(attr.val), true
+			case ETHTOOL_A_HEADER_FLAGS:
+				if len(attr.val) >= 4 {
+					h.Flags = u32At(attr.val, 0)
+				}
+			}
+		}
+
+		return h, true
+	}
+
+	return h, false
+}
+
+// FlagNames returns the names of the flags, e.g. "compact-bitsets" and
+// "omit-reply".
+func (h Header) FlagNames() []string {
+	var names []string
+	for i, name := range headerFlags {
+		if h.Flags&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		want  Header
+		found bool
+		flags []string
+	}{
+		{
+			name: "name and flags",
+			data: concat(
+				nested(ETHTOOL_A_REQUEST_HEADER,
+					attrString(ETHTOOL_A_HEADER_DEV_NAME, "eth0"),
+					attrU32(ETHTOOL_A_HEADER_FLAGS, ETHTOOL_FLAG_COMPACT_BITSETS|ETHTOOL_FLAG_OMIT_REPLY),
+				),
+				attrU32(2, 1),
+			),
+			want:  Header{DevName: "eth0", HasDevName: true, Flags: 3},
+			found: true,
+			flags: []string{"compact-bitsets", "omit-reply"},
+		},
+		{
+			name: "index after other attributes",
+			data: concat(
+				attrU32(2, 1),
+				nested(ETHTOOL_A_REQUEST_HEADER, attrU32(ETHTOOL_A_HEADER_DEV_INDEX, 7)),
+			),
+			want:  Header{DevIndex: 7},
+			found: true,
+		},
+		{
+			name:  "truncated name",
+			data:  nested(ETHTOOL_A_REQUEST_HEADER, attr(ETHTOOL_A_HEADER_DEV_NAME, []byte("eth1"))),
+			want:  Header{DevName: "eth1", HasDevName: true},
+			found: true,
+		},
+		{
+			name:  "empty name",
+			data:  nested(ETHTOOL_A_REQUEST_HEADER, attrString(ETHTOOL_A_HEADER_DEV_NAME, "")),
+			want:  Header{HasDevName: true},
+			found: true,
+		},
+		{
+			name: "missing",
+			data: attrU32(2, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, found := ParseHeader(tt.data)
+			if h != tt.want || found != tt.found {
+				t.Errorf("ParseHeader() = %+v, %v, want %+v, %v", h, found, tt.want, tt.found)
+			}
+			if flags := h.FlagNames(); !reflect.DeepEqual(flags, tt.flags) {
+				t.Errorf("FlagNames() = %q, want %q", flags, tt.flags)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// From include/uapi/linux/ethtool.h and include/uapi/linux/ethtool_netlink.h
+const (
+	// The offsets in struct ethtool_cmd.
+	cmdAdvertising = 8
+	cmdSpeed       = 12
+	cmdDuplex      = 14
+	cmdPort        = 15
+	cmdAutoneg     = 18
+	cmdSpeedHi     = 28
+	cmdMdixCtrl    = 31
+	sizeofCmd      = 44
+
+	// The offsets in struct ethtool_link_settings.
+	linkSpeed        = 4
+	linkDuplex       = 8
+	linkPort         = 9
+	linkAutoneg      = 11
+	linkMdixCtrl     = 14
+	linkMasksNwords  = 15
+	linkMasterSlave  = 17
+	linkModeMasks    = 48
+	sizeofLinkHeader = 48
+
+	speedUnknown = 0xffffffff
+
+	ETHTOOL_A_LINKINFO_PORT         = 2
+	ETHTOOL_A_LINKINFO_TP_MDIX_CTRL = 5
+
+	ETHTOOL_A_LINKMODES_AUTONEG          = 2
+	ETHTOOL_A_LINKMODES_OURS             = 3
+	ETHTOOL_A_LINKMODES_SPEED            = 5
+	ETHTOOL_A_LINKMODES_DUPLEX           = 6
+	ETHTOOL_A_LINKMODES_MASTER_SLAVE_CFG = 7
+	ETHTOOL_A_LINKMODES_LANES            = 9
+)
+
+// LinkModeNames reads the names of the link modes by their bits, e.g.
+// 25000baseSR/Full, i.e. the ETH_SS_LINK_MODES string set of the kernel.
+func LinkModeNames() ([]string, error) {
+	return stringSet(ethSsLinkModes)
+}
+
+var duplexes = map[uint8]string{
+	0: "half", // DUPLEX_HALF
+	1: "full", // DUPLEX_FULL
+}
+
+var ports = map[uint8]string{
+	0x00: "tp",    // PORT_TP
+	0x01: "aui",   // PORT_AUI
+	0x02: "bnc",   // PORT_BNC
+	0x03: "mii",   // PORT_MII
+	0x04: "fibre", // PORT_FIBRE
+	0x05: "da",    // PORT_DA
+}
+
+// mdixCtrls are the names of ethtool -s mdix by ETH_TP_MDI_*.
+var mdixCtrls = map[uint8]string{
+	1: "off",  // ETH_TP_MDI
+	2: "on",   // ETH_TP_MDI_X
+	3: "auto", // ETH_TP_MDI_AUTO
+}
+
+// masterSlaveCfgs are the names of ethtool -s master-slave by
+// MASTER_SLAVE_CFG_*.
+var masterSlaveCfgs = map[uint8]string{
+	2: "preferred-master", // MASTER_SLAVE_CFG_MASTER_PREFERRED
+	3: "preferred-slave",  // MASTER_SLAVE_CFG_SLAVE_PREFERRED
+	4: "forced-master",    // MASTER_SLAVE_CFG_MASTER_FORCE
+	5: "forced-slave",     // MASTER_SLAVE_CFG_SLAVE_FORCE
+}
+
+// linkParams collects the link parameters in the order of ethtool -s, of
+// which the unknown values are skipped.
+type linkParams []string
+
+func (p *linkParams) speed(speed uint32) {
+	if speed != speedUnknown && speed != 0 {
+		*p = append(*p, fmt.Sprintf("speed %d", speed))
+	}
+}
+
+func (p *linkParams) named(param string, names map[uint8]string, v uint8) {
+	if name, ok := names[v]; ok {
+		*p = append(*p, param+" "+name)
+	}
+}
+
+func (p *linkParams) autoneg(on bool) {
+	*p = append(*p, "autoneg "+onOff(on))
+}
+
+// advertise lists the names of the link modes of the bitmap in u32 words.
+func (p *linkParams) advertise(d *Decoder, words []uint32) {
+	var names []string
+	for i, w := range words {
+		for bit := 0; bit < 32; bit++ {
+			if w&(1<<bit) != 0 {
+				names = append(names, d.linkMode(i*32+bit))
+			}
+		}
+	}
+	if len(names) != 0 {
+		*p = append(*p, "advertise "+strings.Join(names, " "))
+	}
+}
+
+func (p linkParams) String() string {
+	return strings.Join(p, " ")
+}
+
+// ethtoolCmd renders struct ethtool_cmd of the deprecated ETHTOOL_SSET, e.g.
+// "speed 10000 duplex full port fibre autoneg off advertise 10000baseT/Full".
+func (d *Decoder) ethtoolCmd(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofCmd {
+		return ""
+	}
+
+	var p linkParams
+	p.speed(uint32(u16At(data, cmdSpeedHi))<<16 | uint32(u16At(data, cmdSpeed)))
+	p.named("duplex", duplexes, data[cmdDuplex])
+	p.named("port", ports, data[cmdPort])
+	p.autoneg(data[cmdAutoneg] != 0)
+	p.named("mdix", mdixCtrls, data[cmdMdixCtrl])
+	p.advertise(d, []uint32{u32At(data, cmdAdvertising)})
+
+	return p.String()
+}
+
+// linkSettings renders struct ethtool_link_settings of
+// ETHTOOL_SLINKSETTINGS, followed by the supported, advertising and
+// lp_advertising link mode masks.
+func (d *Decoder) linkSettings(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofLinkHeader {
+		return ""
+	}
+
+	var p linkParams
+	p.speed(u32At(data, linkSpeed))
+	p.named("duplex", duplexes, data[linkDuplex])
+	p.named("port", ports, data[linkPort])
+	p.autoneg(data[linkAutoneg] != 0)
+	p.named("mdix", mdixCtrls, data[linkMdixCtrl])
+	p.named("master-slave", masterSlaveCfgs, data[linkMasterSlave])
+	if nwords := int(int8(data[linkMasksNwords])); nwords > 0 {
+		masks := data[linkModeMasks:]
+		if len(masks) >= 2*nwords*4 {
+			p.advertise(d, u32s(masks[nwords*4:], nwords))
+		}
+	}
+
+	return p.String()
+}
+
+// linkInfoMsg renders the attributes of ETHTOOL_MSG_LINKINFO_SET, e.g. "port
+// tp mdix auto".
+func (d *Decoder) linkInfoMsg(cmd GenlCmd, data []byte) string {
+	var p linkParams
+	for _, attr := range parseAttrs(data) {
+		if len(attr.val) < 1 {
+			continue
+		}
+		switch attr.typ {
+		case ETHTOOL_A_LINKINFO_PORT:
+			p.named("port", ports, attr.val[0])
+		case ETHTOOL_A_LINKINFO_TP_MDIX_CTRL:
+			p.named("mdix", mdixCtrls, attr.val[0])
+		}
+	}
+
+	return p.String()
+}
+
+// linkModesMsg renders the attributes of ETHTOOL_MSG_LINKMODES_SET, e.g.
+// "speed 25000 duplex full autoneg off lanes 1" or "autoneg on advertise
+// 25000baseSR/Full on 10000baseSR/Full off".
+func (d *Decoder) linkModesMsg(cmd GenlCmd, data []byte) string {
+	var (
+		p       linkParams
+		speed   uint32
+		lanes   uint32
+		ours    []byte
+		autoneg = -1
+		duplex  = -1
+		msCfg   = -1
+	)
+	for _, attr := range parseAttrs(data) {
+		switch attr.typ {
+		case ETHTOOL_A_LINKMODES_AUTONEG:
+			if len(attr.val) >= 1 {
+				autoneg = int(attr.val[0])
+			}
+		case ETHTOOL_A_LINKMODES_OURS:
+			ours = attr.val
+		case ETHTOOL_A_LINKMODES_SPEED:
+			if len(attr.val) >= 4 {
+				speed = u32At(attr.val, 0)
+			}
+		case ETHTOOL_A_LINKMODES_DUPLEX:
+			if len(attr.val) >= 1 {
+				duplex = int(attr.val[0])
+			}
+		case ETHTOOL_A_LINKMODES_MASTER_SLAVE_CFG:
+			if len(attr.val) >= 1 {
+				msCfg = int(attr.val[0])
+			}
+		case ETHTOOL_A_LINKMODES_LANES:
+			if len(attr.val) >= 4 {
+				lanes = u32At(attr.val, 0)
+			}
+		}
+	}
+
+	p.speed(speed)
+	if duplex >= 0 {
+		p.named("duplex", duplexes, uint8(duplex))
+	}
+	if autoneg >= 0 {
+		p.autoneg(autoneg != 0)
+	}
+	if msCfg >= 0 {
+		p.named("master-slave", masterSlaveCfgs, uint8(msCfg))
+	}
+	if lanes != 0 {
+		p = append(p, fmt.Sprintf("lanes %d", lanes))
+	}
+	if ours != nil {
+		if modes := bitset(ours, d.linkMode); modes != "" {
+			p = append(p, "advertise "+modes)
+		}
+	}
+
+	return p.String()
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// linkDecoder names the first link modes only, the others are shown by their
+// bits.
+var linkDecoder = &Decoder{LinkModes: []string{"10baseT/Half", "10baseT/Full", "100baseT/Half", "100baseT/Full"}}
+
+func TestEthtoolCmd(t *testing.T) {
+	// struct ethtool_cmd of ethtool -s eth0 speed 100000 duplex full port
+	// fibre autoneg off mdix on
+	sset := make([]byte, sizeofCmd)
+	putU32(sset, 0, uint32(ETHTOOL_SSET))
+	putU32(sset, cmdAdvertising, 1<<1|1<<3|1<<12)
+	binary.NativeEndian.PutUint16(sset[cmdSpeed:], 100000&0xffff)
+	binary.NativeEndian.PutUint16(sset[cmdSpeedHi:], 100000>>16)
+	sset[cmdDuplex] = 1
+	sset[cmdPort] = 0x04
+	sset[cmdMdixCtrl] = 2
+
+	unknown := make([]byte, sizeofCmd)
+	putU32(unknown, 0, uint32(ETHTOOL_SSET))
+	binary.NativeEndian.PutUint16(unknown[cmdSpeed:], 0xffff)
+	binary.NativeEndian.PutUint16(unknown[cmdSpeedHi:], 0xffff)
+	unknown[cmdDuplex] = 0xff
+	unknown[cmdPort] = 0xff
+	unknown[cmdAutoneg] = 1
+
+	testIoctl(t, linkDecoder, []ioctlTest{
+		{"forced", ETHTOOL_SSET, sset, "speed 100000 duplex full port fibre autoneg off mdix on advertise 10baseT/Full 100baseT/Full link-mode-12"},
+		{"unknown", ETHTOOL_SSET, unknown, "autoneg on"},
+		{"short", ETHTOOL_SSET, sset[:sizeofCmd-1], ""},
+	})
+}
+
+// linkSettingsData builds struct ethtool_link_settings with the supported,
+// advertising and lp_advertising masks of nwords u32s.
+func linkSettingsData(speed uint32, nwords int, advertising ...uint32) []byte {
+	data := make([]byte, sizeofLinkHeader+3*nwords*4)
+	putU32(data, 0, uint32(ETHTOOL_SLINKSETTINGS))
+	putU32(data, linkSpeed, speed)
+	data[linkDuplex] = 1
+	data[linkPort] = 0x00
+	data[linkMdixCtrl] = 3
+	data[linkMasterSlave] = 4
+	data[linkMasksNwords] = uint8(int8(nwords))
+	for i, w := range advertising {
+		putU32(data, linkModeMasks+(nwords+i)*4, w)
+	}
+
+	return data
+}
+
+func TestLinkSettings(t *testing.T) {
+	autoneg := linkSettingsData(speedUnknown, 2, 1<<3, 1<<1)
+	autoneg[linkAutoneg] = 1
+
+	// A negative number of the words, as in the handshake of ethtool,
+	// carries no masks.
+	handshake := linkSettingsData(speedUnknown, 0)
+	nwords := int8(-3)
+	handshake[linkMasksNwords] = uint8(nwords)
+
+	testIoctl(t, linkDecoder, []ioctlTest{
+		{"forced", ETHTOOL_SLINKSETTINGS, linkSettingsData(25000, 1), "speed 25000 duplex full port tp autoneg off mdix auto master-slave forced-master"},
+		{"advertise", ETHTOOL_SLINKSETTINGS, autoneg, "duplex full port tp autoneg on mdix auto master-slave forced-master advertise 100baseT/Full link-mode-33"},
+		{"negative nwords", ETHTOOL_SLINKSETTINGS, handshake, "duplex full port tp autoneg off mdix auto master-slave forced-master"},
+		{"truncated masks", ETHTOOL_SLINKSETTINGS, autoneg[:sizeofLinkHeader+4], "duplex full port tp autoneg on mdix auto master-slave forced-master"},
+		{"short", ETHTOOL_SLINKSETTINGS, autoneg[:sizeofLinkHeader-1], ""},
+	})
+}
+
+func TestLinkMsgs(t *testing.T) {
+	testGenl(t, linkDecoder, []genlTest{
+		{
+			name: "linkinfo",
+			cmd:  ETHTOOL_MSG_LINKINFO_SET,
+			data: concat(
+				attr(ETHTOOL_A_LINKINFO_PORT, []byte{0x00}),
+				attr(ETHTOOL_A_LINKINFO_TP_MDIX_CTRL, []byte{3}),
+			),
+			want: "port tp mdix auto",
+		},
+		{
+			name: "forced",
+			cmd:  ETHTOOL_MSG_LINKMODES_SET,
+			data: concat(
+				attr(ETHTOOL_A_LINKMODES_AUTONEG, []byte{0}),
+				attrU32(ETHTOOL_A_LINKMODES_SPEED, 25000),
+				attr(ETHTOOL_A_LINKMODES_DUPLEX, []byte{1}),
+				attrU32(ETHTOOL_A_LINKMODES_LANES, 1),
+			),
+			want: "speed 25000 duplex full autoneg off lanes 1",
+		},
+		{
+			name: "advertise",
+			cmd:  ETHTOOL_MSG_LINKMODES_SET,
+			data: concat(
+				attr(ETHTOOL_A_LINKMODES_AUTONEG, []byte{1}),
+				attr(ETHTOOL_A_LINKMODES_MASTER_SLAVE_CFG, []byte{2}),
+				nested(ETHTOOL_A_LINKMODES_OURS,
+					attrU32(ETHTOOL_A_BITSET_SIZE, 4),
+					attrU32(ETHTOOL_A_BITSET_VALUE, 1<<3),
+					attrU32(ETHTOOL_A_BITSET_MASK, 1<<1|1<<3),
+				),
+			),
+			want: "autoneg on master-slave preferred-master advertise 10baseT/Full off 100baseT/Full on",
+		},
+	})
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotifyCmd is the ETHTOOL_MSG_* command of the ethtool genetlink message
+// sent by the kernel, of which the *_NTF ones are the notifications
+// multicast on changes.
+type NotifyCmd uint8
+
+const (
+	ETHTOOL_MSG_KERNEL_NONE NotifyCmd = iota
+	ETHTOOL_MSG_STRSET_GET_REPLY
+	ETHTOOL_MSG_LINKINFO_GET_REPLY
+	ETHTOOL_MSG_LINKINFO_NTF
+	ETHTOOL_MSG_LINKMODES_GET_REPLY
+	ETHTOOL_MSG_LINKMODES_NTF
+	ETHTOOL_MSG_LINKSTATE_GET_REPLY
+	ETHTOOL_MSG_DEBUG_GET_REPLY
+	ETHTOOL_MSG_DEBUG_NTF
+	ETHTOOL_MSG_WOL_GET_REPLY
+	ETHTOOL_MSG_WOL_NTF
+	ETHTOOL_MSG_FEATURES_GET_REPLY
+	ETHTOOL_MSG_FEATURES_SET_REPLY
+	ETHTOOL_MSG_FEATURES_NTF
+	ETHTOOL_MSG_PRIVFLAGS_GET_REPLY
+	ETHTOOL_MSG_PRIVFLAGS_NTF
+	ETHTOOL_MSG_RINGS_GET_REPLY
+	ETHTOOL_MSG_RINGS_NTF
+	ETHTOOL_MSG_CHANNELS_GET_REPLY
+	ETHTOOL_MSG_CHANNELS_NTF
+	ETHTOOL_MSG_COALESCE_GET_REPLY
+	ETHTOOL_MSG_COALESCE_NTF
+	ETHTOOL_MSG_PAUSE_GET_REPLY
+	ETHTOOL_MSG_PAUSE_NTF
+	ETHTOOL_MSG_EEE_GET_REPLY
+	ETHTOOL_MSG_EEE_NTF
+	ETHTOOL_MSG_TSINFO_GET_REPLY
+	ETHTOOL_MSG_CABLE_TEST_NTF
+	ETHTOOL_MSG_CABLE_TEST_TDR_NTF
+	ETHTOOL_MSG_TUNNEL_INFO_GET_REPLY
+	ETHTOOL_MSG_FEC_GET_REPLY
+	ETHTOOL_MSG_FEC_NTF
+	ETHTOOL_MSG_MODULE_EEPROM_GET_REPLY
+	ETHTOOL_MSG_STATS_GET_REPLY
+	ETHTOOL_MSG_PHC_VCLOCKS_GET_REPLY
+	ETHTOOL_MSG_MODULE_GET_REPLY
+	ETHTOOL_MSG_MODULE_NTF
+	ETHTOOL_MSG_PSE_GET_REPLY
+	ETHTOOL_MSG_RSS_GET_REPLY
+	ETHTOOL_MSG_PLCA_GET_CFG_REPLY
+	ETHTOOL_MSG_PLCA_GET_STATUS_REPLY
+	ETHTOOL_MSG_PLCA_NTF
+	ETHTOOL_MSG_MM_GET_REPLY
+	ETHTOOL_MSG_MM_NTF
+	ETHTOOL_MSG_MODULE_FW_FLASH_NTF
+	ETHTOOL_MSG_PHY_GET_REPLY
+	ETHTOOL_MSG_PHY_NTF
+	ETHTOOL_MSG_TSCONFIG_GET_REPLY
+	ETHTOOL_MSG_TSCONFIG_SET_REPLY
+	ETHTOOL_MSG_PSE_NTF
+	ETHTOOL_MSG_RSS_NTF
+	ETHTOOL_MSG_RSS_CREATE_ACT_REPLY
+	ETHTOOL_MSG_RSS_CREATE_NTF
+	ETHTOOL_MSG_RSS_DELETE_NTF
+)
+
+var notifyCmds = []string{
+	"ETHTOOL_MSG_KERNEL_NONE",
+	"ETHTOOL_MSG_STRSET_GET_REPLY",
+	"ETHTOOL_MSG_LINKINFO_GET_REPLY",
+	"ETHTOOL_MSG_LINKINFO_NTF",
+	"ETHTOOL_MSG_LINKMODES_GET_REPLY",
+	"ETHTOOL_MSG_LINKMODES_NTF",
+	"ETHTOOL_MSG_LINKSTATE_GET_REPLY",
+	"ETHTOOL_MSG_DEBUG_GET_REPLY",
+	"ETHTOOL_MSG_DEBUG_NTF",
+	"ETHTOOL_MSG_WOL_GET_REPLY",
+	"ETHTOOL_MSG_WOL_NTF",
+	"ETHTOOL_MSG_FEATURES_GET_REPLY",
+	"ETHTOOL_MSG_FEATURES_SET_REPLY",
+	"ETHTOOL_MSG_FEATURES_NTF",
+	"ETHTOOL_MSG_PRIVFLAGS_GET_REPLY",
+	"ETHTOOL_MSG_PRIVFLAGS_NTF",
+	"ETHTOOL_MSG_RINGS_GET_REPLY",
+	"ETHTOOL_MSG_RINGS_NTF",
+	"ETHTOOL_MSG_CHANNELS_GET_REPLY",
+	"ETHTOOL_MSG_CHANNELS_NTF",
+	"ETHTOOL_MSG_COALESCE_GET_REPLY",
+	"ETHTOOL_MSG_COALESCE_NTF",
+	"ETHTOOL_MSG_PAUSE_GET_REPLY",
+	"ETHTOOL_MSG_PAUSE_NTF",
+	"ETHTOOL_MSG_EEE_GET_REPLY",
+	"ETHTOOL_MSG_EEE_NTF",
+	"ETHTOOL_MSG_TSINFO_GET_REPLY",
+	"ETHTOOL_MSG_CABLE_TEST_NTF",
+	"ETHTOOL_MSG_CABLE_TEST_TDR_NTF",
+	"ETHTOOL_MSG_TUNNEL_INFO_GET_REPLY",
+	"ETHTOOL_MSG_FEC_GET_REPLY",
+	"ETHTOOL_MSG_FEC_NTF",
+	"ETHTOOL_MSG_MODULE_EEPROM_GET_REPLY",
+	"ETHTOOL_MSG_STATS_GET_REPLY",
+	"ETHTOOL_MSG_PHC_VCLOCKS_GET_REPLY",
+	"ETHTOOL_MSG_MODULE_GET_REPLY",
+	"ETHTOOL_MSG_MODULE_NTF",
+	"ETHTOOL_MSG_PSE_GET_REPLY",
+	"ETHTOOL_MSG_RSS_GET_REPLY",
+	"ETHTOOL_MSG_PLCA_GET_CFG_REPLY",
+	"ETHTOOL_MSG_PLCA_GET_STATUS_REPLY",
+	"ETHTOOL_MSG_PLCA_NTF",
+	"ETHTOOL_MSG_MM_GET_REPLY",
+	"ETHTOOL_MSG_MM_NTF",
+	"ETHTOOL_MSG_MODULE_FW_FLASH_NTF",
+	"ETHTOOL_MSG_PHY_GET_REPLY",
+	"ETHTOOL_MSG_PHY_NTF",
+	"ETHTOOL_MSG_TSCONFIG_GET_REPLY",
+	"ETHTOOL_MSG_TSCONFIG_SET_REPLY",
+	"ETHTOOL_MSG_PSE_NTF",
+	"ETHTOOL_MSG_RSS_NTF",
+	"ETHTOOL_MSG_RSS_CREATE_ACT_REPLY",
+	"ETHTOOL_MSG_RSS_CREATE_NTF",
+	"ETHTOOL_MSG_RSS_DELETE_NTF",
+}
+
+func (cmd NotifyCmd) String() string {
+	if int(cmd) < len(notifyCmds) {
+		return notifyCmds[cmd]
+	}
+
+	return fmt.Sprintf("Unknown[%x]", int(cmd))
+}
+
+// IsNotification reports whether the message is a notification rather than
+// a reply to a request.
+func (cmd NotifyCmd) IsNotification() bool {
+	return strings.HasSuffix(cmd.String(), "_NTF")
+}
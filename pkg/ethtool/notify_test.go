@@ -0,0 +1,39 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+func TestNotifyCmd(t *testing.T) {
+	for _, tt := range []struct {
+		cmd          NotifyCmd
+		name         string
+		notification bool
+	}{
+		{ETHTOOL_MSG_FEATURES_NTF, "ETHTOOL_MSG_FEATURES_NTF", true},
+		{ETHTOOL_MSG_FEATURES_GET_REPLY, "ETHTOOL_MSG_FEATURES_GET_REPLY", false},
+		{ETHTOOL_MSG_MODULE_FW_FLASH_NTF, "ETHTOOL_MSG_MODULE_FW_FLASH_NTF", true},
+		{ETHTOOL_MSG_RSS_DELETE_NTF, "ETHTOOL_MSG_RSS_DELETE_NTF", true},
+		{ETHTOOL_MSG_RSS_DELETE_NTF + 1, "Unknown[36]", false},
+	} {
+		if got := tt.cmd.String(); got != tt.name {
+			t.Errorf("String() = %s, want %s", got, tt.name)
+		}
+		if got := tt.cmd.IsNotification(); got != tt.notification {
+			t.Errorf("%s.IsNotification() = %v, want %v", tt.name, got, tt.notification)
+		}
+	}
+
+	if len(notifyCmds) != int(ETHTOOL_MSG_RSS_DELETE_NTF)+1 {
+		t.Errorf("%d names for %d messages", len(notifyCmds), ETHTOOL_MSG_RSS_DELETE_NTF+1)
+	}
+}
+
+// The notifications without decoders are reported without details.
+func TestNotify(t *testing.T) {
+	testNotify(t, new(Decoder), []notifyTest{
+		{"features", ETHTOOL_MSG_FEATURES_NTF, attrU32(2, 1), ""},
+		{"unknown", ETHTOOL_MSG_RSS_DELETE_NTF + 1, nil, ""},
+	})
+}
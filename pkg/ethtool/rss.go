@@ -0,0 +1,305 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// From include/uapi/linux/ethtool.h and include/uapi/linux/ethtool_netlink.h
+const (
+	// The offsets in struct ethtool_rxfh_indir.
+	indirSize      = 4
+	indirRingIndex = 8
+
+	// The offsets in struct ethtool_rxfh.
+	rxfhRssContext = 4
+	rxfhIndirSize  = 8
+	rxfhKeySize    = 12
+	rxfhHfunc      = 16
+	rxfhInputXfrm  = 17
+	rxfhRssConfig  = 24
+
+	ethRxfhContextAlloc  = 0xffffffff
+	ethRxfhIndirNoChange = 0xffffffff
+	rxhXfrmNoChange      = 0xff
+
+	ETHTOOL_A_RSS_CONTEXT    = 2
+	ETHTOOL_A_RSS_HFUNC      = 3
+	ETHTOOL_A_RSS_INDIR      = 4
+	ETHTOOL_A_RSS_HKEY       = 5
+	ETHTOOL_A_RSS_INPUT_XFRM = 6
+)
+
+// rssHashFuncs are the ETH_RSS_HASH_* functions by their bits.
+var rssHashFuncs = []string{"toeplitz", "xor", "crc32"}
+
+// rssXfrms are the RXH_XFRM_* input transformations by their bits.
+var rssXfrms = []string{"symmetric-xor", "symmetric-or-xor"}
+
+func bitNames(v uint32, names []string) string {
+	var set []string
+	for i, name := range names {
+		if v&(1<<i) != 0 {
+			set = append(set, name)
+			v &^= 1 << i
+		}
+	}
+	if v != 0 {
+		set = append(set, fmt.Sprintf("0x%x", v))
+	}
+
+	return strings.Join(set, "|")
+}
+
+// rssConfig is the RSS configuration passed by ETHTOOL_SRSSH or the genl RSS
+// messages, of which the unset members are not changed.
+type rssConfig struct {
+	// context is empty for the default context, or "new" for the one to
+	// create.
+	context   string
+	delete    bool
+	hfunc     uint32
+	inputXfrm uint32
+	// indirSize is the entries of the indirection table, 0 to reset it to
+	// the default or -1 if it is not changed. indir is the entries copied,
+	// which may be fewer.
+	indirSize int
+	indir     []uint32
+	// keySize is the length of the hash key, and key is the bytes copied.
+	keySize int
+	key     []byte
+	// showKey shows the hash key itself rather than its hash.
+	showKey bool
+}
+
+func (d *Decoder) newRssConfig() rssConfig {
+	return rssConfig{
+		inputXfrm: rxhXfrmNoChange,
+		indirSize: -1,
+		showKey:   d != nil && d.ShowSecrets,
+	}
+}
+
+// String returns the summary of the configuration, e.g. "context 1; hfunc
+// toeplitz; indir: 128 entries spread over queues 0-7 (even)". The hash key
+// is hashed by SHA-256 unless it is shown.
+func (rc *rssConfig) String() string {
+	var parts []string
+	if rc.context != "" {
+		parts = append(parts, "context "+rc.context)
+	}
+	if rc.delete {
+		return strings.Join(append(parts, "delete"), "; ")
+	}
+
+	if rc.hfunc != 0 {
+		parts = append(parts, "hfunc "+bitNames(rc.hfunc, rssHashFuncs))
+	}
+	if rc.inputXfrm == 0 {
+		parts = append(parts, "xfrm none")
+	} else if rc.inputXfrm != rxhXfrmNoChange {
+		parts = append(parts, "xfrm "+bitNames(rc.inputXfrm, rssXfrms))
+	}
+	if rc.indirSize >= 0 {
+		parts = append(parts, indirSummary(rc.indirSize, rc.indir))
+	}
+	if rc.keySize != 0 {
+		key := fmt.Sprintf("hkey: %d bytes", rc.keySize)
+		if len(rc.key) == rc.keySize {
+			if rc.showKey {
+				key += " " + colonHex(rc.key)
+			} else {
+				sum := sha256.Sum256(rc.key)
+				key += fmt.Sprintf(" sha256:%x", sum[:8])
+			}
+		}
+		parts = append(parts, key)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// indirSummary summarizes how the indirection table spreads the flows over
+// the queues, e.g. "indir: 128 entries spread over queues 0-7 (even)" or
+// "indir: 128 entries over queues 0-3 weight 2, 4-7 weight 1".
+func indirSummary(size int, indir []uint32) string {
+	if size == 0 {
+		return "indir: default"
+	}
+
+	s := fmt.Sprintf("indir: %d entries", size)
+	if len(indir) == 0 {
+		return s
+	}
+
+	// The weights are unknown if the table is not fully copied.
+	partial := len(indir) < size
+	if partial {
+		s += fmt.Sprintf(" (%d copied)", len(indir))
+	}
+
+	counts := make(map[uint32]int)
+	for _, q := range indir {
+		counts[q]++
+	}
+
+	queues := make([]uint32, 0, len(counts))
+	unit := 0
+	for q, n := range counts {
+		queues = append(queues, q)
+		unit = gcd(unit, n)
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i] < queues[j] })
+
+	if len(queues) == 1 {
+		return s + fmt.Sprintf(" all on queue %d", queues[0])
+	}
+
+	// Group the consecutive queues of the same weight.
+	type group struct {
+		first, last uint32
+		weight      int
+	}
+	var groups []group
+	even := true
+	for _, q := range queues {
+		weight := counts[q] / unit
+		if partial {
+			weight = 0
+		}
+		even = even && weight == 1
+		if n := len(groups); n != 0 && groups[n-1].last+1 == q && groups[n-1].weight == weight {
+			groups[n-1].last = q
+			continue
+		}
+		groups = append(groups, group{first: q, last: q, weight: weight})
+	}
+
+	ranges := make([]string, 0, len(groups))
+	for _, g := range groups {
+		r := fmt.Sprint(g.first)
+		if g.last != g.first {
+			r += fmt.Sprintf("-%d", g.last)
+		}
+		if !even && !partial {
+			r += fmt.Sprintf(" weight %d", g.weight)
+		}
+		ranges = append(ranges, r)
+	}
+
+	if even {
+		return s + fmt.Sprintf(" spread over queues %s (even)", strings.Join(ranges, ", "))
+	}
+
+	return s + fmt.Sprintf(" over queues %s", strings.Join(ranges, ", "))
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+func u32s(b []byte, n int) []uint32 {
+	n = min(n, len(b)/4)
+	v := make([]uint32, n)
+	for i := range v {
+		v[i] = u32At(b, i*4)
+	}
+
+	return v
+}
+
+// rxfhIndir renders struct ethtool_rxfh_indir of ETHTOOL_GRXFHINDIR
+// and ETHTOOL_SRXFHINDIR.
+func (d *Decoder) rxfhIndir(cmd IoctlCmd, data []byte) string {
+	if len(data) < indirRingIndex {
+		return ""
+	}
+
+	size := int(u32At(data, indirSize))
+	return indirSummary(size, u32s(data[indirRingIndex:], size))
+}
+
+// rxfh renders struct ethtool_rxfh of ETHTOOL_GRSSH and ETHTOOL_SRSSH,
+// followed by the indirection table and the hash key.
+func (d *Decoder) rxfh(cmd IoctlCmd, data []byte) string {
+	if len(data) < rxfhRssConfig {
+		return ""
+	}
+
+	rc := d.newRssConfig()
+	context := u32At(data, rxfhRssContext)
+	switch context {
+	case 0:
+	case ethRxfhContextAlloc:
+		rc.context = "new"
+	default:
+		rc.context = fmt.Sprint(context)
+	}
+
+	rc.hfunc = uint32(data[rxfhHfunc])
+	rc.inputXfrm = uint32(data[rxfhInputXfrm])
+
+	config := data[rxfhRssConfig:]
+	if size := u32At(data, rxfhIndirSize); size != ethRxfhIndirNoChange {
+		rc.indirSize = int(size)
+		rc.indir = u32s(config, rc.indirSize)
+		config = config[min(len(config), rc.indirSize*4):]
+	}
+	rc.keySize = int(u32At(data, rxfhKeySize))
+	rc.key = config[:min(len(config), rc.keySize)]
+
+	// Resetting the indirection table of a non-default context deletes it.
+	if cmd == ETHTOOL_SRSSH && context != 0 && context != ethRxfhContextAlloc && rc.indirSize == 0 {
+		rc.delete = true
+	}
+
+	return rc.String()
+}
+
+// rssMsg renders the attributes of ETHTOOL_MSG_RSS_SET,
+// ETHTOOL_MSG_RSS_CREATE_ACT and ETHTOOL_MSG_RSS_DELETE_ACT.
+func (d *Decoder) rssMsg(cmd GenlCmd, data []byte) string {
+	rc := d.newRssConfig()
+	for _, attr := range parseAttrs(data) {
+		switch attr.typ {
+		case ETHTOOL_A_RSS_CONTEXT:
+			if len(attr.val) >= 4 {
+				rc.context = fmt.Sprint(u32At(attr.val, 0))
+			}
+		case ETHTOOL_A_RSS_HFUNC:
+			if len(attr.val) >= 4 {
+				rc.hfunc = u32At(attr.val, 0)
+			}
+		case ETHTOOL_A_RSS_INPUT_XFRM:
+			if len(attr.val) >= 4 {
+				rc.inputXfrm = u32At(attr.val, 0)
+			}
+		case ETHTOOL_A_RSS_INDIR:
+			rc.indirSize = attr.len / 4
+			rc.indir = u32s(attr.val, rc.indirSize)
+		case ETHTOOL_A_RSS_HKEY:
+			rc.keySize = attr.len
+			rc.key = attr.val
+		}
+	}
+
+	switch cmd {
+	case ETHTOOL_MSG_RSS_CREATE_ACT:
+		if rc.context == "" {
+			rc.context = "new"
+		}
+	case ETHTOOL_MSG_RSS_DELETE_ACT:
+		rc.delete = true
+	}
+
+	return rc.String()
+}
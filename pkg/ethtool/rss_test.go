@@ -0,0 +1,117 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// rxfh builds struct ethtool_rxfh followed by the indirection table and the
+// hash key.
+func rxfh(cmd IoctlCmd, context, indirSize uint32, hfunc, xfrm byte, indir []uint32, key []byte) []byte {
+	data := make([]byte, rxfhRssConfig)
+	putU32(data, 0, uint32(cmd))
+	putU32(data, rxfhRssContext, context)
+	putU32(data, rxfhIndirSize, indirSize)
+	putU32(data, rxfhKeySize, uint32(len(key)))
+	data[rxfhHfunc] = hfunc
+	data[rxfhInputXfrm] = xfrm
+	data = append(data, u32Bytes(indir...)...)
+
+	return append(data, key...)
+}
+
+func TestRss(t *testing.T) {
+	key := []byte{0x6d, 0x5a, 0x56, 0xda}
+	sum := sha256.Sum256(key)
+	hashed := fmt.Sprintf("sha256:%x", sum[:8])
+
+	testIoctl(t, new(Decoder), []ioctlTest{
+		{
+			// ethtool -X eth0 equal 4 hfunc toeplitz
+			name: "even",
+			cmd:  ETHTOOL_SRSSH,
+			data: rxfh(ETHTOOL_SRSSH, 0, 8, 1, rxhXfrmNoChange, []uint32{0, 1, 2, 3, 0, 1, 2, 3}, nil),
+			want: "hfunc toeplitz; indir: 8 entries spread over queues 0-3 (even)",
+		},
+		{
+			// ethtool -X eth0 weight 2 1 1
+			name: "weighted",
+			cmd:  ETHTOOL_SRSSH,
+			data: rxfh(ETHTOOL_SRSSH, 0, 4, 0, rxhXfrmNoChange, []uint32{0, 0, 1, 2}, nil),
+			want: "indir: 4 entries over queues 0 weight 2, 1-2 weight 1",
+		},
+		{
+			name: "partial",
+			cmd:  ETHTOOL_SRSSH,
+			data: rxfh(ETHTOOL_SRSSH, 0, 4, 0, rxhXfrmNoChange, []uint32{0, 1}, nil),
+			want: "indir: 4 entries (2 copied) over queues 0-1",
+		},
+		{
+			// ethtool -X eth0 hkey 6d:5a:56:da
+			name: "key hashed",
+			cmd:  ETHTOOL_SRSSH,
+			data: rxfh(ETHTOOL_SRSSH, 0, ethRxfhIndirNoChange, 0, rxhXfrmNoChange, nil, key),
+			want: "hkey: 4 bytes " + hashed,
+		},
+		{
+			// ethtool -X eth0 context new xfrm symmetric-xor
+			name: "new context",
+			cmd:  ETHTOOL_SRSSH,
+			data: rxfh(ETHTOOL_SRSSH, ethRxfhContextAlloc, 0, 0, 1, nil, nil),
+			want: "context new; xfrm symmetric-xor; indir: default",
+		},
+		{
+			// ethtool -X eth0 context 3 delete
+			name: "delete context",
+			cmd:  ETHTOOL_SRSSH,
+			data: rxfh(ETHTOOL_SRSSH, 3, 0, 0, rxhXfrmNoChange, nil, nil),
+			want: "context 3; delete",
+		},
+		{
+			name: "indir",
+			cmd:  ETHTOOL_SRXFHINDIR,
+			data: u32Bytes(uint32(ETHTOOL_SRXFHINDIR), 4, 1, 1, 1, 1),
+			want: "indir: 4 entries all on queue 1",
+		},
+	})
+
+	testIoctl(t, &Decoder{ShowSecrets: true}, []ioctlTest{
+		{
+			name: "key shown",
+			cmd:  ETHTOOL_SRSSH,
+			data: rxfh(ETHTOOL_SRSSH, 0, ethRxfhIndirNoChange, 0, rxhXfrmNoChange, nil, key),
+			want: "hkey: 4 bytes 6d:5a:56:da",
+		},
+	})
+}
+
+func TestRssMsg(t *testing.T) {
+	testGenl(t, new(Decoder), []genlTest{
+		{
+			name: "set",
+			cmd:  ETHTOOL_MSG_RSS_SET,
+			data: concat(
+				attrU32(ETHTOOL_A_RSS_CONTEXT, 1),
+				attrU32(ETHTOOL_A_RSS_HFUNC, 1<<1),
+				attr(ETHTOOL_A_RSS_INDIR, u32Bytes(0, 1, 0, 1)),
+			),
+			want: "context 1; hfunc xor; indir: 4 entries spread over queues 0-1 (even)",
+		},
+		{
+			name: "create",
+			cmd:  ETHTOOL_MSG_RSS_CREATE_ACT,
+			data: attrU32(ETHTOOL_A_RSS_INPUT_XFRM, 0),
+			want: "context new; xfrm none",
+		},
+		{
+			name: "delete",
+			cmd:  ETHTOOL_MSG_RSS_DELETE_ACT,
+			data: attrU32(ETHTOOL_A_RSS_CONTEXT, 2),
+			want: "context 2; delete",
+		},
+	})
+}
@@ -0,0 +1,300 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// From include/uapi/linux/ethtool.h
+const (
+	sizeofRxnfc = 192
+
+	// The offsets in struct ethtool_rxnfc.
+	rxnfcFlowType   = 4
+	rxnfcData       = 8
+	rxnfcFs         = 16
+	rxnfcRssContext = 184
+
+	// The offsets in struct ethtool_rx_flow_spec.
+	fsFlowType   = 0
+	fsHu         = 4
+	fsHext       = 56
+	fsMu         = 76
+	fsMext       = 128
+	fsRingCookie = 152
+	fsLocation   = 160
+
+	// The offsets in struct ethtool_flow_ext.
+	extHdest     = 2
+	extVlanEtype = 8
+	extVlanTci   = 10
+	extData      = 12
+
+	flowExt    = 0x80000000
+	flowMacExt = 0x40000000
+	flowRss    = 0x20000000
+
+	rxClsFlowDisc   = ^uint64(0)
+	rxClsFlowWake   = ^uint64(0) - 1
+	rxClsLocSpecial = 0x80000000
+)
+
+var flowTypes = map[uint32]string{
+	0x01: "tcp4",
+	0x02: "udp4",
+	0x03: "sctp4",
+	0x04: "ah4",
+	0x05: "tcp6",
+	0x06: "udp6",
+	0x07: "sctp6",
+	0x08: "ah6",
+	0x09: "ah4",
+	0x0a: "esp4",
+	0x0b: "ah6",
+	0x0c: "esp6",
+	0x0d: "ip4",
+	0x0e: "ip6",
+	0x10: "ip4",
+	0x11: "ip6",
+	0x12: "ether",
+}
+
+func flowTypeName(flowType uint32) string {
+	flowType &^= flowExt | flowMacExt | flowRss
+	if name, ok := flowTypes[flowType]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("0x%x", flowType)
+}
+
+// rxhFields are the RXH_* bits of the hashed fields, by their letters of
+// ethtool -N rx-flow-hash.
+var rxhFields = []struct {
+	bit    uint64
+	letter byte
+}{
+	{1 << 1, 'm'},  // RXH_L2DA
+	{1 << 2, 'v'},  // RXH_VLAN
+	{1 << 3, 't'},  // RXH_L3_PROTO
+	{1 << 4, 's'},  // RXH_IP_SRC
+	{1 << 5, 'd'},  // RXH_IP_DST
+	{1 << 6, 'f'},  // RXH_L4_B_0_1
+	{1 << 7, 'n'},  // RXH_L4_B_2_3
+	{1 << 8, 'e'},  // RXH_GTP_TEID
+	{1 << 9, 'l'},  // RXH_IP6_FL
+	{1 << 31, 'r'}, // RXH_DISCARD
+}
+
+// rxnfc renders struct ethtool_rxnfc in the style of ethtool -N.
+func (d *Decoder) rxnfc(cmd IoctlCmd, data []byte) string {
+	// The struct ethtool_rx_ntuple of the obsolete ETHTOOL_SRXNTUPLE has
+	// been removed from the kernel, which rejects the command.
+	if cmd == ETHTOOL_SRXNTUPLE {
+		return "obsolete n-tuple filter, unsupported by the kernel"
+	}
+
+	if len(data) < sizeofRxnfc {
+		return ""
+	}
+
+	switch cmd {
+	case ETHTOOL_GRXFH, ETHTOOL_SRXFH:
+		return decodeFlowHash(data)
+	case ETHTOOL_SRXCLSRLDEL:
+		return fmt.Sprintf("delete %d", u32At(data, rxnfcFs+fsLocation))
+	case ETHTOOL_GRXCLSRULE:
+		// Only the location is set before the kernel fills the rule.
+		if u32At(data, rxnfcFs+fsFlowType) == 0 {
+			return fmt.Sprintf("loc %d", u32At(data, rxnfcFs+fsLocation))
+		}
+		return decodeFlowSpec(data)
+	default:
+		return decodeFlowSpec(data)
+	}
+}
+
+func decodeFlowHash(data []byte) string {
+	flowType := u32At(data, rxnfcFlowType)
+	hash := u64At(data, rxnfcData)
+
+	var fields []byte
+	for _, f := range rxhFields {
+		if hash&f.bit != 0 {
+			fields = append(fields, f.letter)
+		}
+	}
+	if len(fields) == 0 {
+		fields = []byte("none")
+	}
+
+	s := fmt.Sprintf("rx-flow-hash %s %s", flowTypeName(flowType), fields)
+	if flowType&flowRss != 0 {
+		s += fmt.Sprintf(" context %d", u32At(data, rxnfcRssContext))
+	}
+
+	return s
+}
+
+// flowSpec builds the rule in the style of ethtool -N.
+type flowSpec struct {
+	parts []string
+}
+
+func (fs *flowSpec) add(parts ...string) {
+	fs.parts = append(fs.parts, parts...)
+}
+
+// field adds the field if it is matched. The mask follows the value if the
+// field is matched partially, in the convention of ethtool, in which the set
+// bits are ignored, the inverse of the mask in the kernel.
+func (fs *flowSpec) field(name string, val, mask []byte, format func([]byte) string) {
+	if bytes.Count(mask, []byte{0}) == len(mask) {
+		return
+	}
+
+	fs.add(name, format(val))
+	if bytes.Count(mask, []byte{0xff}) != len(mask) {
+		ignored := make([]byte, len(mask))
+		for i := range mask {
+			ignored[i] = ^mask[i]
+		}
+		fs.add("m", format(ignored))
+	}
+}
+
+func formatIP(b []byte) string {
+	addr, _ := netip.AddrFromSlice(b)
+	return addr.String()
+}
+
+func formatMAC(b []byte) string {
+	return net.HardwareAddr(b).String()
+}
+
+func formatUint(b []byte) string {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return fmt.Sprint(v)
+}
+
+func formatHex(b []byte) string {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return fmt.Sprintf("0x%x", v)
+}
+
+// specField is a field of the union h_u of struct ethtool_rx_flow_spec.
+type specField struct {
+	name   string
+	off    int
+	size   int
+	format func([]byte) string
+}
+
+var (
+	ip4Fields = func(l4 specField) []specField {
+		return []specField{
+			{"src-ip", 0, 4, formatIP},
+			{"dst-ip", 4, 4, formatIP},
+			l4,
+			{"tos", 12, 1, formatHex},
+		}
+	}
+	ip6Fields = func(l4 specField) []specField {
+		return []specField{
+			{"src-ip", 0, 16, formatIP},
+			{"dst-ip", 16, 16, formatIP},
+			l4,
+			{"tclass", 36, 1, formatHex},
+		}
+	}
+
+	ports4 = []specField{{"src-port", 8, 2, formatUint}, {"dst-port", 10, 2, formatUint}}
+	ports6 = []specField{{"src-port", 32, 2, formatUint}, {"dst-port", 34, 2, formatUint}}
+
+	// specFields are the fields of the flow types, by the members of h_u.
+	specFields = map[string][]specField{
+		"tcp4":  append(ip4Fields(ports4[0]), ports4[1]),
+		"udp4":  append(ip4Fields(ports4[0]), ports4[1]),
+		"sctp4": append(ip4Fields(ports4[0]), ports4[1]),
+		"ah4":   ip4Fields(specField{"spi", 8, 4, formatUint}),
+		"esp4":  ip4Fields(specField{"spi", 8, 4, formatUint}),
+		"ip4":   append(ip4Fields(specField{"l4data", 8, 4, formatHex}), specField{"l4proto", 14, 1, formatUint}),
+		"tcp6":  append(ip6Fields(ports6[0]), ports6[1]),
+		"udp6":  append(ip6Fields(ports6[0]), ports6[1]),
+		"sctp6": append(ip6Fields(ports6[0]), ports6[1]),
+		"ah6":   ip6Fields(specField{"spi", 32, 4, formatUint}),
+		"esp6":  ip6Fields(specField{"spi", 32, 4, formatUint}),
+		"ip6":   append(ip6Fields(specField{"l4data", 32, 4, formatHex}), specField{"l4proto", 37, 1, formatUint}),
+		"ether": {
+			{"dst-mac", 0, 6, formatMAC},
+			{"src-mac", 6, 6, formatMAC},
+			{"proto", 12, 2, formatHex},
+		},
+	}
+)
+
+// decodeFlowSpec renders the struct ethtool_rx_flow_spec in struct
+// ethtool_rxnfc as ethtool -N flow-type ... action ... loc ...
+func decodeFlowSpec(data []byte) string {
+	spec := data[rxnfcFs:]
+	flowType := u32At(spec, fsFlowType)
+	name := flowTypeName(flowType)
+
+	var fs flowSpec
+	fs.add("flow-type", name)
+
+	hu, mu := spec[fsHu:fsHext], spec[fsMu:fsMext]
+	for _, f := range specFields[name] {
+		fs.field(f.name, hu[f.off:f.off+f.size], mu[f.off:f.off+f.size], f.format)
+	}
+
+	hext, mext := spec[fsHext:fsMu], spec[fsMext:fsRingCookie]
+	if flowType&flowExt != 0 {
+		fs.field("vlan-etype", hext[extVlanEtype:extVlanEtype+2], mext[extVlanEtype:extVlanEtype+2], formatHex)
+		fs.field("vlan", hext[extVlanTci:extVlanTci+2], mext[extVlanTci:extVlanTci+2], formatHex)
+		fs.field("user-def", hext[extData:extData+8], mext[extData:extData+8], formatHex)
+	}
+	if flowType&flowMacExt != 0 {
+		fs.field("dst-mac", hext[extHdest:extHdest+6], mext[extHdest:extHdest+6], formatMAC)
+	}
+
+	switch cookie := u64At(spec, fsRingCookie); cookie {
+	case rxClsFlowDisc:
+		fs.add("action", "-1")
+	case rxClsFlowWake:
+		fs.add("action", "-2")
+	default:
+		// ETHTOOL_RX_FLOW_SPEC_RING_VF and ETHTOOL_RX_FLOW_SPEC_RING
+		if vf := cookie >> 32 & 0xff; vf != 0 {
+			fs.add("vf", fmt.Sprint(vf), "queue", fmt.Sprint(uint32(cookie)))
+		} else {
+			fs.add("action", fmt.Sprint(uint32(cookie)))
+		}
+	}
+
+	if flowType&flowRss != 0 {
+		fs.add("context", fmt.Sprint(u32At(data, rxnfcRssContext)))
+	}
+
+	// The kernel picks the location of RX_CLS_LOC_ANY, RX_CLS_LOC_FIRST and
+	// RX_CLS_LOC_LAST, and writes it back.
+	if loc := u32At(spec, fsLocation); loc&rxClsLocSpecial == 0 {
+		fs.add("loc", fmt.Sprint(loc))
+	}
+
+	return strings.Join(fs.parts, " ")
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+// rxnfcRule builds struct ethtool_rxnfc of the rule, the way ethtool -N does.
+// The fields fill h_u and h_ext, and m_u and m_ext.
+func rxnfcRule(cmd IoctlCmd, flowType uint32, ring uint64, loc uint32, fields func(hu, mu []byte)) []byte {
+	data := make([]byte, sizeofRxnfc)
+	putU32(data, 0, uint32(cmd))
+	spec := data[rxnfcFs:]
+	putU32(spec, fsFlowType, flowType)
+	if fields != nil {
+		fields(spec[fsHu:fsMu], spec[fsMu:fsRingCookie])
+	}
+	putU64(spec, fsRingCookie, ring)
+	putU32(spec, fsLocation, loc)
+
+	return data
+}
+
+func TestRxnfc(t *testing.T) {
+	// ethtool -N eth0 flow-type tcp4 src-ip 192.168.1.1 dst-port 80 action 2 loc 5
+	tcp4 := rxnfcRule(ETHTOOL_SRXCLSRLINS, 0x01, 2, 5, func(hu, mu []byte) {
+		copy(hu[0:], []byte{192, 168, 1, 1})
+		copy(mu[0:], []byte{0xff, 0xff, 0xff, 0xff})
+		copy(hu[10:], []byte{0, 80})
+		copy(mu[10:], []byte{0xff, 0xff})
+	})
+
+	// ethtool -N eth0 flow-type udp4 dst-ip 10.0.0.0 m 0.0.0.255 action -1,
+	// of which the location is RX_CLS_LOC_ANY
+	udp4 := rxnfcRule(ETHTOOL_SRXCLSRLINS, 0x02, rxClsFlowDisc, 0xffffffff, func(hu, mu []byte) {
+		copy(hu[4:], []byte{10, 0, 0, 0})
+		copy(mu[4:], []byte{0xff, 0xff, 0xff, 0})
+	})
+
+	// ethtool -N eth0 flow-type ether proto 0x88f7 vlan 0x100 vf 1 queue 3 loc 1
+	ether := rxnfcRule(ETHTOOL_SRXCLSRLINS, 0x12|flowExt, 1<<32|3, 1, func(hu, mu []byte) {
+		copy(hu[12:], []byte{0x88, 0xf7})
+		copy(mu[12:], []byte{0xff, 0xff})
+		hext, mext := hu[fsHext-fsHu:], mu[fsMext-fsMu:]
+		copy(hext[extVlanTci:], []byte{0x01, 0x00})
+		copy(mext[extVlanTci:], []byte{0xff, 0xff})
+	})
+
+	// ethtool -N eth0 flow-type tcp6 context 1 action 0 loc 2
+	tcp6 := rxnfcRule(ETHTOOL_SRXCLSRLINS, 0x05|flowRss, 0, 2, nil)
+	putU32(tcp6, rxnfcRssContext, 1)
+
+	// ethtool -N eth0 rx-flow-hash tcp4 sdfn
+	hash := make([]byte, sizeofRxnfc)
+	putU32(hash, rxnfcFlowType, 0x01)
+	putU64(hash, rxnfcData, 1<<4|1<<5|1<<6|1<<7)
+
+	testIoctl(t, new(Decoder), []ioctlTest{
+		{"tcp4 rule", ETHTOOL_SRXCLSRLINS, tcp4, "flow-type tcp4 src-ip 192.168.1.1 dst-port 80 action 2 loc 5"},
+		{"udp4 masked discard", ETHTOOL_SRXCLSRLINS, udp4, "flow-type udp4 dst-ip 10.0.0.0 m 0.0.0.255 action -1"},
+		{"ether vlan vf", ETHTOOL_SRXCLSRLINS, ether, "flow-type ether proto 0x88f7 vlan 0x100 vf 1 queue 3 loc 1"},
+		{"tcp6 context", ETHTOOL_SRXCLSRLINS, tcp6, "flow-type tcp6 action 0 context 1 loc 2"},
+		{"rule filled", ETHTOOL_GRXCLSRULE, tcp4, "flow-type tcp4 src-ip 192.168.1.1 dst-port 80 action 2 loc 5"},
+		{"rule location", ETHTOOL_GRXCLSRULE, rxnfcRule(ETHTOOL_GRXCLSRULE, 0, 0, 7, nil), "loc 7"},
+		{"delete", ETHTOOL_SRXCLSRLDEL, rxnfcRule(ETHTOOL_SRXCLSRLDEL, 0, 0, 5, nil), "delete 5"},
+		{"flow hash", ETHTOOL_SRXFH, hash, "rx-flow-hash tcp4 sdfn"},
+		{"ntuple", ETHTOOL_SRXNTUPLE, nil, "obsolete n-tuple filter, unsupported by the kernel"},
+		{"short", ETHTOOL_SRXCLSRLINS, tcp4[:sizeofRxnfc-1], ""},
+	})
+}
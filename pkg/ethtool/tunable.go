@@ -0,0 +1,157 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "fmt"
+
+// From include/uapi/linux/ethtool.h
+const (
+	// The offsets in struct ethtool_tunable.
+	tunableID     = 4
+	tunableType   = 8
+	sizeofTunable = 16
+
+	ETHTOOL_RX_COPYBREAK          = 1
+	ETHTOOL_TX_COPYBREAK          = 2
+	ETHTOOL_PFC_PREVENTION_TOUT   = 3
+	ETHTOOL_TX_COPYBREAK_BUF_SIZE = 4
+
+	ETHTOOL_PHY_DOWNSHIFT      = 1
+	ETHTOOL_PHY_FAST_LINK_DOWN = 2
+	ETHTOOL_PHY_EDPD           = 3
+
+	ETHTOOL_TUNABLE_U8  = 1
+	ETHTOOL_TUNABLE_U16 = 2
+	ETHTOOL_TUNABLE_U32 = 3
+	ETHTOOL_TUNABLE_U64 = 4
+	ETHTOOL_TUNABLE_S8  = 6
+	ETHTOOL_TUNABLE_S16 = 7
+	ETHTOOL_TUNABLE_S32 = 8
+	ETHTOOL_TUNABLE_S64 = 9
+
+	downshiftDevDefaultCount = 0xff
+	fastLinkDownOn           = 0
+	fastLinkDownOff          = 0xff
+	edpdDefaultTxMsecs       = 0xffff
+	edpdNoTx                 = 0xfffe
+	pfcStormPreventionAuto   = 0xffff
+)
+
+// tunableValue reads the value of the tunable by its type, which is false if
+// the type is unknown or the value is not copied.
+func tunableValue(typ uint32, b []byte) (int64, bool) {
+	switch {
+	case typ == ETHTOOL_TUNABLE_U8 && len(b) >= 1:
+		return int64(b[0]), true
+	case typ == ETHTOOL_TUNABLE_U16 && len(b) >= 2:
+		return int64(u16At(b, 0)), true
+	case typ == ETHTOOL_TUNABLE_U32 && len(b) >= 4:
+		return int64(u32At(b, 0)), true
+	case typ == ETHTOOL_TUNABLE_U64 && len(b) >= 8:
+		return int64(u64At(b, 0)), true
+	case typ == ETHTOOL_TUNABLE_S8 && len(b) >= 1:
+		return int64(int8(b[0])), true
+	case typ == ETHTOOL_TUNABLE_S16 && len(b) >= 2:
+		return int64(int16(u16At(b, 0))), true
+	case typ == ETHTOOL_TUNABLE_S32 && len(b) >= 4:
+		return int64(int32(u32At(b, 0))), true
+	case typ == ETHTOOL_TUNABLE_S64 && len(b) >= 8:
+		return int64(u64At(b, 0)), true
+	default:
+		return 0, false
+	}
+}
+
+// tunableName is the name of the tunable, and how its value is shown if not
+// as a number.
+type tunableName struct {
+	name   string
+	format func(v int64) string
+}
+
+// tunables are the names of the tunables of ETHTOOL_[GS]TUNABLE by their ids.
+var tunables = map[uint32]tunableName{
+	ETHTOOL_RX_COPYBREAK: {"rx-copybreak", nil},
+	ETHTOOL_TX_COPYBREAK: {"tx-copybreak", nil},
+	ETHTOOL_PFC_PREVENTION_TOUT: {"pfc-prevention-tout", func(v int64) string {
+		switch v {
+		case 0:
+			return "off"
+		case pfcStormPreventionAuto:
+			return "auto"
+		default:
+			return fmt.Sprintf("%d", v)
+		}
+	}},
+	ETHTOOL_TX_COPYBREAK_BUF_SIZE: {"tx-copybreak-buf-size", nil},
+}
+
+// phyTunables are the names of the tunables of ETHTOOL_PHY_[GS]TUNABLE by
+// their ids, of which the values are shown in the style of ethtool
+// --set-phy-tunable.
+var phyTunables = map[uint32]tunableName{
+	ETHTOOL_PHY_DOWNSHIFT: {"downshift", func(v int64) string {
+		switch v {
+		case 0:
+			return "off"
+		case downshiftDevDefaultCount:
+			return "on"
+		default:
+			return fmt.Sprintf("on count %d", v)
+		}
+	}},
+	ETHTOOL_PHY_FAST_LINK_DOWN: {"fast-link-down", func(v int64) string {
+		switch v {
+		case fastLinkDownOff:
+			return "off"
+		case fastLinkDownOn:
+			return "on"
+		default:
+			return fmt.Sprintf("on msecs %d", v)
+		}
+	}},
+	ETHTOOL_PHY_EDPD: {"energy-detect-power-down", func(v int64) string {
+		switch v {
+		case 0:
+			return "off"
+		case edpdDefaultTxMsecs:
+			return "on"
+		case edpdNoTx:
+			return "on without tx pulses"
+		default:
+			return fmt.Sprintf("on msecs %d", v)
+		}
+	}},
+}
+
+// tunable renders struct ethtool_tunable of ETHTOOL_[GS]TUNABLE and
+// ETHTOOL_PHY_[GS]TUNABLE followed by the value, e.g. "rx-copybreak 256" or
+// "downshift on count 3". The value of the get commands is the one read on
+// success.
+func (d *Decoder) tunable(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofTunable {
+		return ""
+	}
+
+	known, prefix := tunables, "tunable"
+	if cmd == ETHTOOL_PHY_GTUNABLE || cmd == ETHTOOL_PHY_STUNABLE {
+		known, prefix = phyTunables, "phy-tunable"
+	}
+
+	id := u32At(data, tunableID)
+	t, ok := known[id]
+	if !ok {
+		t.name = fmt.Sprintf("%s-%d", prefix, id)
+	}
+
+	v, ok := tunableValue(u32At(data, tunableType), data[sizeofTunable:])
+	switch {
+	case !ok:
+		return t.name
+	case t.format != nil:
+		return t.name + " " + t.format(v)
+	default:
+		return fmt.Sprintf("%s %d", t.name, v)
+	}
+}
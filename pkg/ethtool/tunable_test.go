@@ -0,0 +1,61 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+// tunable builds struct ethtool_tunable followed by the value.
+func tunable(cmd IoctlCmd, id, typ uint32, val []byte) []byte {
+	data := u32Bytes(uint32(cmd), id, typ, uint32(len(val)))
+	return append(data, val...)
+}
+
+func TestTunable(t *testing.T) {
+	testIoctl(t, new(Decoder), []ioctlTest{
+		{
+			// ethtool --set-tunable eth0 rx-copybreak 256
+			name: "rx-copybreak",
+			cmd:  ETHTOOL_STUNABLE,
+			data: tunable(ETHTOOL_STUNABLE, ETHTOOL_RX_COPYBREAK, ETHTOOL_TUNABLE_U32, u32Bytes(256)),
+			want: "rx-copybreak 256",
+		},
+		{
+			name: "pfc-prevention-tout auto",
+			cmd:  ETHTOOL_STUNABLE,
+			data: tunable(ETHTOOL_STUNABLE, ETHTOOL_PFC_PREVENTION_TOUT, ETHTOOL_TUNABLE_U16, []byte{0xff, 0xff}),
+			want: "pfc-prevention-tout auto",
+		},
+		{
+			// ethtool --set-phy-tunable eth0 downshift on count 3
+			name: "downshift",
+			cmd:  ETHTOOL_PHY_STUNABLE,
+			data: tunable(ETHTOOL_PHY_STUNABLE, ETHTOOL_PHY_DOWNSHIFT, ETHTOOL_TUNABLE_U8, []byte{3}),
+			want: "downshift on count 3",
+		},
+		{
+			name: "fast-link-down off",
+			cmd:  ETHTOOL_PHY_STUNABLE,
+			data: tunable(ETHTOOL_PHY_STUNABLE, ETHTOOL_PHY_FAST_LINK_DOWN, ETHTOOL_TUNABLE_U8, []byte{fastLinkDownOff}),
+			want: "fast-link-down off",
+		},
+		{
+			name: "edpd without tx pulses",
+			cmd:  ETHTOOL_PHY_STUNABLE,
+			data: tunable(ETHTOOL_PHY_STUNABLE, ETHTOOL_PHY_EDPD, ETHTOOL_TUNABLE_U16, []byte{0xfe, 0xff}),
+			want: "energy-detect-power-down on without tx pulses",
+		},
+		{
+			name: "get without value",
+			cmd:  ETHTOOL_GTUNABLE,
+			data: tunable(ETHTOOL_GTUNABLE, ETHTOOL_TX_COPYBREAK, ETHTOOL_TUNABLE_U32, nil),
+			want: "tx-copybreak",
+		},
+		{
+			name: "unknown",
+			cmd:  ETHTOOL_PHY_STUNABLE,
+			data: tunable(ETHTOOL_PHY_STUNABLE, 9, ETHTOOL_TUNABLE_S8, []byte{0xff}),
+			want: "phy-tunable-9 -1",
+		},
+	})
+}
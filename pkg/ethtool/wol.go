@@ -0,0 +1,165 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// From include/uapi/linux/ethtool.h and include/uapi/linux/ethtool_netlink.h
+const (
+	// The offsets in struct ethtool_wolinfo.
+	wolinfoWolopts = 8
+	sizeofWolinfo  = 18
+
+	wakeMagicSecure = 1 << 6
+
+	ETHTOOL_A_WOL_MODES  = 2
+	ETHTOOL_A_WOL_SOPASS = 3
+
+	ETHTOOL_A_DEBUG_MSGMASK = 2
+)
+
+// wolModes are the WAKE_* modes by their bits, by the names of the
+// ETH_SS_WOL_MODES string set and the letters of ethtool -s wol.
+var wolModes = []struct {
+	name   string
+	letter byte
+}{
+	{"phy", 'p'},
+	{"ucast", 'u'},
+	{"mcast", 'm'},
+	{"bcast", 'b'},
+	{"arp", 'a'},
+	{"magic", 'g'},
+	{"magicsecure", 's'},
+	{"filter", 'f'},
+}
+
+func wolModeName(bit int) string {
+	if bit < len(wolModes) {
+		return wolModes[bit].name
+	}
+
+	return fmt.Sprintf("wol-mode-%d", bit)
+}
+
+// wolLetters renders the names of the WAKE_* modes by the letters of ethtool
+// -s wol, e.g. "gs", or "d" for none.
+func wolLetters(names []string) string {
+	var sb strings.Builder
+	for _, name := range names {
+		known := false
+		for _, m := range wolModes {
+			if m.name == name {
+				sb.WriteByte(m.letter)
+				known = true
+				break
+			}
+		}
+		if !known {
+			sb.WriteString("(" + name + ")")
+		}
+	}
+	if sb.Len() == 0 {
+		return "d"
+	}
+
+	return sb.String()
+}
+
+// swol renders struct ethtool_wolinfo of ETHTOOL_SWOL, e.g. "wol gs sopass
+// set". The SecureOn password only matters with the s mode, and is never
+// shown.
+func (d *Decoder) swol(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofWolinfo {
+		return ""
+	}
+
+	wolopts := u32At(data, wolinfoWolopts)
+	var names []string
+	for bit := 0; bit < 32; bit++ {
+		if wolopts&(1<<bit) != 0 {
+			names = append(names, wolModeName(bit))
+		}
+	}
+
+	s := "wol " + wolLetters(names)
+	if wolopts&wakeMagicSecure != 0 {
+		s += " sopass set"
+	}
+
+	return s
+}
+
+// wolMsg renders the attributes of ETHTOOL_MSG_WOL_SET, e.g. "wol g" or
+// "sopass set".
+func (d *Decoder) wolMsg(cmd GenlCmd, data []byte) string {
+	var parts []string
+	for _, attr := range parseAttrs(data) {
+		switch attr.typ {
+		case ETHTOOL_A_WOL_MODES:
+			var names []string
+			for _, b := range parseBitset(attr.val, wolModeName) {
+				if b.on {
+					names = append(names, b.name)
+				}
+			}
+			parts = append(parts, "wol "+wolLetters(names))
+		case ETHTOOL_A_WOL_SOPASS:
+			parts = append(parts, "sopass set")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// msgClasses are the NETIF_MSG_* classes by their bits, i.e. the
+// ETH_SS_MSG_CLASSES string set.
+var msgClasses = []string{
+	"drv", "probe", "link", "timer", "ifdown", "ifup", "rx_err", "tx_err",
+	"tx_queued", "intr", "tx_done", "rx_status", "pktdata", "hw", "wol",
+}
+
+func msgClass(bit int) string {
+	if bit < len(msgClasses) {
+		return msgClasses[bit]
+	}
+
+	return fmt.Sprintf("msg-class-%d", bit)
+}
+
+// smsglvl renders the message level of ETHTOOL_SMSGLVL, e.g. "msglvl 0x7
+// (drv probe link)".
+func (d *Decoder) smsglvl(cmd IoctlCmd, data []byte) string {
+	if len(data) < sizeofEthtoolValue {
+		return ""
+	}
+
+	level := u32At(data, 4)
+	var names []string
+	for bit := 0; bit < 32; bit++ {
+		if level&(1<<bit) != 0 {
+			names = append(names, msgClass(bit))
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+
+	return fmt.Sprintf("msglvl 0x%x (%s)", level, strings.Join(names, " "))
+}
+
+// debugMsg renders the message mask of ETHTOOL_MSG_DEBUG_SET, e.g. "msglvl
+// drv on link off".
+func (d *Decoder) debugMsg(cmd GenlCmd, data []byte) string {
+	for _, attr := range parseAttrs(data) {
+		if attr.typ == ETHTOOL_A_DEBUG_MSGMASK {
+			return "msglvl " + bitset(attr.val, msgClass)
+		}
+	}
+
+	return ""
+}
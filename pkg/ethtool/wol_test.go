@@ -0,0 +1,56 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package ethtool
+
+import "testing"
+
+// The passwords are never shown, whether the secrets are shown or not.
+func TestWol(t *testing.T) {
+	// struct ethtool_wolinfo of ethtool -s eth0 wol gs sopass 00:11:22:33:44:55
+	swol := make([]byte, sizeofWolinfo)
+	putU32(swol, 0, uint32(ETHTOOL_SWOL))
+	putU32(swol, wolinfoWolopts, 1<<5|wakeMagicSecure)
+	copy(swol[12:], []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+
+	disabled := make([]byte, sizeofWolinfo)
+	putU32(disabled, 0, uint32(ETHTOOL_SWOL))
+
+	sopass := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	for _, d := range []*Decoder{{}, {ShowSecrets: true}} {
+		testIoctl(t, d, []ioctlTest{
+			{"swol secure", ETHTOOL_SWOL, swol, "wol gs sopass set"},
+			{"swol disabled", ETHTOOL_SWOL, disabled, "wol d"},
+			{"swol short", ETHTOOL_SWOL, swol[:sizeofWolinfo-1], ""},
+			{"msglvl", ETHTOOL_SMSGLVL, u32Bytes(uint32(ETHTOOL_SMSGLVL), 0x7), "msglvl 0x7 (drv probe link)"},
+			{"msglvl none", ETHTOOL_SMSGLVL, u32Bytes(uint32(ETHTOOL_SMSGLVL), 0), "msglvl 0x0 (none)"},
+		})
+
+		testGenl(t, d, []genlTest{
+			{
+				name: "wol msg",
+				cmd:  ETHTOOL_MSG_WOL_SET,
+				data: concat(
+					nested(ETHTOOL_A_WOL_MODES,
+						attr(ETHTOOL_A_BITSET_NOMASK, nil),
+						attrU32(ETHTOOL_A_BITSET_SIZE, 8),
+						attrU32(ETHTOOL_A_BITSET_VALUE, 1<<5|1<<6),
+					),
+					attr(ETHTOOL_A_WOL_SOPASS, sopass),
+				),
+				want: "wol gs sopass set",
+			},
+			{
+				name: "debug msg",
+				cmd:  ETHTOOL_MSG_DEBUG_SET,
+				data: nested(ETHTOOL_A_DEBUG_MSGMASK,
+					attrU32(ETHTOOL_A_BITSET_SIZE, 15),
+					attrU32(ETHTOOL_A_BITSET_VALUE, 1<<0),
+					attrU32(ETHTOOL_A_BITSET_MASK, 1<<0|1<<2),
+				),
+				want: "msglvl drv on link off",
+			},
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+)
+
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -cc clang -no-strip -no-global-types -target amd64,arm64,riscv64,s390x,ppc64le enforce ../../bpf/enforce.c -- -I../../bpf/headers
+
+// Policy rejects the commands with -EPERM unless they are issued by the
+// allowed executables or from the allowed cgroups. It is enforced by the BPF
+// LSM, which requires CONFIG_BPF_LSM and "bpf" in the lsm= boot parameter.
+type Policy struct {
+	// IoctlCmds and GenlCmds are the commands to reject.
+	IoctlCmds []ethtool.IoctlCmd
+	GenlCmds  []ethtool.GenlCmd
+
+	// AllowExecutables are the paths of the executables allowed to issue
+	// the commands.
+	AllowExecutables []string
+	// AllowCgroups are the paths of the cgroup v2 directories whose
+	// processes, including the ones of the descendant cgroups, are allowed
+	// to issue the commands.
+	AllowCgroups []string
+}
+
+// MutatingPolicy returns the policy rejecting all the commands changing the
+// device or triggering actions on it.
+func MutatingPolicy() Policy {
+	var p Policy
+	for _, cmd := range ethtool.IoctlCmds() {
+		if class := cmd.Class(); class == ethtool.ClassSet || class == ethtool.ClassAction {
+			p.IoctlCmds = append(p.IoctlCmds, cmd)
+		}
+	}
+	for _, cmd := range ethtool.GenlCmds() {
+		if class := cmd.Class(); class == ethtool.ClassSet || class == ethtool.ClassAction {
+			p.GenlCmds = append(p.GenlCmds, cmd)
+		}
+	}
+
+	return p
+}
+
+// WithPolicy enforces p. The rejected commands are delivered as events with
+// VerdictDenied.
+func WithPolicy(p Policy) Option {
+	return func(o *options) {
+		o.policy = &p
+	}
+}
+
+// exeKey is struct exe_key in bpf/enforce.c.
+type exeKey struct {
+	Ino uint64
+	Dev uint32
+	_   uint32
+}
+
+func executableKey(path string) (exeKey, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return exeKey{}, err
+	}
+
+	// The kernel encodes dev_t as major<<20 | minor internally.
+	dev := unix.Major(uint64(st.Dev))<<20 | unix.Minor(uint64(st.Dev))
+	return exeKey{Ino: st.Ino, Dev: dev}, nil
+}
+
+// cgroupID returns the id of the cgroup v2, which is the inode number of its
+// directory.
+func cgroupID(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return 0, fmt.Errorf("%s is not a cgroup directory", path)
+	}
+
+	return st.Ino, nil
+}
+
+func (t *Tracer) loadEnforce(familyID uint16) error {
+	spec, err := loadEnforce()
+	if err != nil {
+		return fmt.Errorf("failed to load enforce spec: %w", err)
+	}
+
+	if err := t.rewriteSpec(spec, familyID); err != nil {
+		return err
+	}
+
+	compat, err := t.hasLSMHook("file_ioctl_compat")
+	if err != nil {
+		return err
+	}
+
+	// bpf_loop() is available to all the program types, while the helpers
+	// of LSM programs cannot be probed.
+	loop := true
+	if err := features.HaveProgramHelper(ebpf.Kprobe, asm.FnLoop); errors.Is(err, ebpf.ErrNotSupported) {
+		loop = false
+	} else if err != nil {
+		return fmt.Errorf("failed to probe bpf_loop: %w", err)
+	}
+
+	// lsm_file_ioctl_compat is left out on the kernels before 6.8, which
+	// have no such hook to load it against, and lsm_netlink_send on the
+	// kernels before 5.17, which have no bpf_loop(), for
+	// lsm_netlink_send_legacy.
+	if !compat {
+		delete(spec.Programs, "lsm_file_ioctl_compat")
+	}
+	if loop {
+		delete(spec.Programs, "lsm_netlink_send_legacy")
+	} else {
+		delete(spec.Programs, "lsm_netlink_send")
+	}
+
+	coll, err := ebpf.NewCollectionWithOptions(spec, ebpf.CollectionOptions{
+		Programs: ebpf.ProgramOptions{
+			KernelTypes: t.opts.kernelTypes,
+		},
+		MapReplacements: map[string]*ebpf.Map{
+			"events": t.obj.Events,
+			"stacks": t.obj.Stacks,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load enforce objects: %w", err)
+	}
+	if err := coll.Assign(&t.enforce.enforceMaps); err != nil {
+		coll.Close()
+		return fmt.Errorf("failed to assign enforce maps: %w", err)
+	}
+	t.enforce.LsmFileIoctl = coll.Programs["lsm_file_ioctl"]
+	t.enforce.LsmFileIoctlCompat = coll.Programs["lsm_file_ioctl_compat"]
+	t.enforce.LsmNetlinkSend = coll.Programs["lsm_netlink_send"]
+	t.enforce.LsmNetlinkSendLegacy = coll.Programs["lsm_netlink_send_legacy"]
+
+	if err := t.SetPolicy(*t.opts.policy); err != nil {
+		return err
+	}
+
+	for _, prog := range coll.Programs {
+		l, err := link.AttachLSM(link.LSMOptions{Program: prog})
+		if err != nil {
+			return fmt.Errorf("failed to attach lsm %s (is bpf in /sys/kernel/security/lsm?): %w", prog, err)
+		}
+
+		t.links = append(t.links, l)
+	}
+
+	return nil
+}
+
+// hasLSMHook reports whether the kernel has the LSM hook, of which the bpf
+// programs attach to bpf_lsm_<hook>().
+func (t *Tracer) hasLSMHook(hook string) (bool, error) {
+	spec := t.opts.kernelTypes
+	if spec == nil {
+		var err error
+		if spec, err = btf.LoadKernelSpec(); err != nil {
+			return false, fmt.Errorf("failed to load kernel btf: %w", err)
+		}
+	}
+
+	var fn *btf.Func
+	err := spec.TypeByName("bpf_lsm_"+hook, &fn)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, btf.ErrNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to find lsm hook %s: %w", hook, err)
+	}
+}
+
+// SetPolicy replaces the policy without detaching the bpf programs. It fails
+// if the tracer is not created with WithPolicy.
+func (t *Tracer) SetPolicy(p Policy) error {
+	if t.enforce.AllowExes == nil {
+		return errors.New("policy is not enforced")
+	}
+
+	exes := make([]exeKey, 0, len(p.AllowExecutables))
+	for _, path := range p.AllowExecutables {
+		key, err := executableKey(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve executable %s: %w", path, err)
+		}
+		exes = append(exes, key)
+	}
+
+	cgroups := make([]uint64, 0, len(p.AllowCgroups))
+	for _, path := range p.AllowCgroups {
+		id, err := cgroupID(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve cgroup %s: %w", path, err)
+		}
+		cgroups = append(cgroups, id)
+	}
+
+	ioctlCmds := make([]uint32, 0, len(p.IoctlCmds))
+	for _, cmd := range p.IoctlCmds {
+		ioctlCmds = append(ioctlCmds, uint32(cmd))
+	}
+
+	genlCmds := make([]uint32, 0, len(p.GenlCmds))
+	for _, cmd := range p.GenlCmds {
+		genlCmds = append(genlCmds, uint32(cmd))
+	}
+
+	// Grant the new allowances before denying the new commands, and revoke
+	// the stale allowances after the stale denials, so that nothing allowed
+	// by both the old and the new policies is ever denied.
+	for _, step := range []struct {
+		m    *ebpf.Map
+		sync func(*ebpf.Map) error
+	}{
+		{t.enforce.AllowExes, func(m *ebpf.Map) error { return addKeys(m, exes) }},
+		{t.enforce.AllowCgroups, func(m *ebpf.Map) error { return addKeys(m, cgroups) }},
+		{t.enforce.DenyIoctlCmds, func(m *ebpf.Map) error { return syncKeys(m, ioctlCmds) }},
+		{t.enforce.DenyGenlCmds, func(m *ebpf.Map) error { return syncKeys(m, genlCmds) }},
+		{t.enforce.AllowCgroups, func(m *ebpf.Map) error { return syncKeys(m, cgroups) }},
+		{t.enforce.AllowExes, func(m *ebpf.Map) error { return syncKeys(m, exes) }},
+	} {
+		if err := step.sync(step.m); err != nil {
+			return fmt.Errorf("failed to update %s: %w", step.m, err)
+		}
+	}
+
+	return nil
+}
+
+func addKeys[K comparable](m *ebpf.Map, keys []K) error {
+	for _, k := range keys {
+		if err := m.Put(k, uint8(1)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncKeys makes the keys of m exactly keys.
+func syncKeys[K comparable](m *ebpf.Map, keys []K) error {
+	if err := addKeys(m, keys); err != nil {
+		return err
+	}
+
+	keep := make(map[K]bool, len(keys))
+	for _, k := range keys {
+		keep[k] = true
+	}
+
+	var (
+		stale []K
+		k     K
+		v     uint8
+	)
+	iter := m.Iterate()
+	for iter.Next(&k, &v) {
+		if !keep[k] {
+			stale = append(stale, k)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range stale {
+		if err := m.Delete(k); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
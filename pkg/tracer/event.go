@@ -0,0 +1,370 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+)
+
+// EventType is the way the ethtool command was issued.
+type EventType uint8
+
+const (
+	EventTypeIoctl EventType = 1
+	EventTypeGenl  EventType = 2
+	// EventTypeKernel is an ethtool function called by an in-kernel
+	// consumer, e.g. bonding, whose IoctlCmd is the equivalent command.
+	EventTypeKernel EventType = 3
+	// EventTypeOp is an ethtool_ops callback of the driver traced by
+	// WithDriverOps, which is not triggered by a traced event.
+	EventTypeOp EventType = 4
+	// EventTypeNotify is an ethtool genetlink notification multicast by the
+	// kernel, whose Parent is the traced command causing it, if any.
+	EventTypeNotify EventType = 5
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventTypeIoctl:
+		return "ioctl"
+	case EventTypeGenl:
+		return "genl"
+	case EventTypeKernel:
+		return "kernel"
+	case EventTypeOp:
+		return "op"
+	case EventTypeNotify:
+		return "notify"
+	default:
+		return fmt.Sprintf("Unknown[%d]", uint8(t))
+	}
+}
+
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *EventType) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "ioctl":
+		*t = EventTypeIoctl
+	case "genl":
+		*t = EventTypeGenl
+	case "kernel":
+		*t = EventTypeKernel
+	case "op":
+		*t = EventTypeOp
+	case "notify":
+		*t = EventTypeNotify
+	default:
+		return fmt.Errorf("unknown event type %q", text)
+	}
+
+	return nil
+}
+
+// Verdict is the decision of the enforcement policy on the command.
+type Verdict uint8
+
+const (
+	VerdictAllowed Verdict = 0
+	VerdictDenied  Verdict = 1
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case VerdictAllowed:
+		return "allowed"
+	case VerdictDenied:
+		return "denied"
+	default:
+		return fmt.Sprintf("Unknown[%d]", uint8(v))
+	}
+}
+
+func (v Verdict) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *Verdict) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "allowed":
+		*v = VerdictAllowed
+	case "denied":
+		*v = VerdictDenied
+	default:
+		return fmt.Errorf("unknown verdict %q", text)
+	}
+
+	return nil
+}
+
+// The ways the genetlink messages identify the netdev.
+const (
+	DevByIndex   = "index"
+	DevByName    = "name"
+	DevByAltname = "altname"
+)
+
+// Event is an ethtool command issued through ioctl or genetlink.
+type Event struct {
+	// Time is when the event was received from the kernel.
+	Time time.Time `json:"time"`
+	Type EventType `json:"type"`
+
+	// IoctlCmd is set when Type is EventTypeIoctl or EventTypeKernel.
+	IoctlCmd ethtool.IoctlCmd `json:"ioctl_cmd,omitempty"`
+	// GenlCmd is set when Type is EventTypeGenl.
+	GenlCmd ethtool.GenlCmd `json:"genl_cmd,omitempty"`
+	// NotifyCmd is set when Type is EventTypeNotify.
+	NotifyCmd ethtool.NotifyCmd `json:"notify_cmd,omitempty"`
+	// Details is the struct passed by the ioctl command or the attributes of
+	// the genetlink message, decoded in the style of the ethtool options,
+	// e.g. "flow-type tcp4 dst-port 80 action 2 loc 1" of
+	// ETHTOOL_SRXCLSRLINS, or empty if it is not decoded.
+	Details string `json:"details,omitempty"`
+	data    []byte
+
+	Ifname string `json:"ifname"`
+	// Ifindex is 0 if the netdev is not found.
+	Ifindex uint32 `json:"ifindex,omitempty"`
+	// DevBy is how the genetlink message identified the netdev, DevByIndex,
+	// DevByName or DevByAltname, set when Type is EventTypeGenl.
+	DevBy string `json:"dev_by,omitempty"`
+	// Altname is the alternative name of the netdev given by the genetlink
+	// message, when DevBy is DevByAltname. Ifname is still the name of the
+	// netdev.
+	Altname string `json:"altname,omitempty"`
+	// HeaderFlags are the ETHTOOL_FLAG_* flags of the genetlink message
+	// header, e.g. "compact-bitsets", "omit-reply" and "stats".
+	HeaderFlags []string `json:"header_flags,omitempty"`
+	// Driver is the driver of the parent device of the netdev, e.g.
+	// mlx5_core, or the link kind of the virtual netdev, e.g. veth.
+	Driver string `json:"driver,omitempty"`
+	// Bus is the bus address of the parent device, e.g. the PCI address
+	// 0000:3b:00.0.
+	Bus string `json:"bus,omitempty"`
+	// Master is the master device of the netdev, e.g. the bond or the
+	// bridge it is enslaved to, read in the network namespace of the
+	// netdev.
+	Master string `json:"master,omitempty"`
+
+	// Caller is the kernel function calling the ethtool function, e.g.
+	// "bond_update_speed_duplex+0x3d [bonding]", set when Type is
+	// EventTypeKernel.
+	Caller string `json:"caller,omitempty"`
+	caller uint64
+
+	Pid  uint32 `json:"pid"`
+	Comm string `json:"comm"`
+
+	// Process is the name of the process, including its parent if the
+	// process is ethtool. It falls back to Comm if the process has exited.
+	Process string `json:"process"`
+	// Container is the short id of the container the process runs in, or
+	// empty if it does not run in a container.
+	Container string `json:"container,omitempty"`
+
+	// Ret is the return value of the command, a negative errno on failure.
+	Ret int32 `json:"ret"`
+	// Duration is how long the kernel took to handle the command. For the
+	// notification ending a module firmware flash, it is how long the flash
+	// took since the flash request.
+	Duration time.Duration `json:"duration"`
+	// Verdict is VerdictDenied if the command was rejected by the
+	// enforcement policy, in which case Ret is -EPERM.
+	Verdict Verdict `json:"verdict,omitempty"`
+
+	Uid uint32 `json:"uid"`
+	Gid uint32 `json:"gid"`
+	// User is the name of Uid, or empty if it is unknown.
+	User string `json:"user,omitempty"`
+	// LoginUid is the audit login uid, the user who logged in and started
+	// the session, which survives su and sudo. It is NoLoginUid if unset.
+	LoginUid uint32 `json:"loginuid"`
+	// LoginUser is the name of LoginUid, or empty if it is unknown.
+	LoginUser string `json:"login_user,omitempty"`
+	// SessionID is the audit session id, NoLoginUid if unset.
+	SessionID uint32 `json:"sessionid"`
+	// TTY is the controlling terminal, e.g. pts/3, or empty if none.
+	TTY string `json:"tty,omitempty"`
+
+	// ID identifies the event, and Parent is the ID of the event triggering
+	// the ethtool_ops callback, or 0 if it is not triggered by a traced
+	// event.
+	ID     uint64 `json:"id,omitempty"`
+	Parent uint64 `json:"parent,omitempty"`
+	// Op and Func are the ethtool_ops member and the driver function of the
+	// callback, set when the event is an ethtool_ops callback.
+	Op   string `json:"op,omitempty"`
+	Func string `json:"func,omitempty"`
+	op   uint32
+
+	// KernelStack and UserStack are the frames of the stacks, the innermost
+	// first, captured by WithStack when the command is issued.
+	KernelStack []string `json:"kernel_stack,omitempty"`
+	UserStack   []string `json:"user_stack,omitempty"`
+	kstack      int32
+	ustack      int32
+
+	// Ops are the ethtool_ops callbacks called while handling the command,
+	// in the order of their return.
+	Ops []*Event `json:"ops,omitempty"`
+}
+
+// NoLoginUid is the unset audit login uid and session id, e.g. of the
+// processes started at boot instead of by a login.
+const NoLoginUid = ^uint32(0)
+
+// isGenl reports whether the command is a genetlink message. The ethtool_ops
+// callbacks carry the command of the triggering event.
+func (e *Event) isGenl() bool {
+	return e.Type == EventTypeGenl || e.Type == EventTypeOp && e.GenlCmd != 0
+}
+
+// Cmd returns the name of the ioctl command or the genetlink message.
+func (e *Event) Cmd() string {
+	if e.Type == EventTypeNotify {
+		return e.NotifyCmd.String()
+	}
+	if e.isGenl() {
+		return e.GenlCmd.String()
+	}
+
+	return e.IoctlCmd.String()
+}
+
+// Class returns what the command does to the device, which is ClassUnknown
+// for the notifications.
+func (e *Event) Class() ethtool.CmdClass {
+	if e.Type == EventTypeNotify {
+		return ethtool.ClassUnknown
+	}
+	if e.isGenl() {
+		return e.GenlCmd.Class()
+	}
+
+	return e.IoctlCmd.Class()
+}
+
+// Failed reports whether the kernel rejected the command.
+func (e *Event) Failed() bool {
+	return e.Ret < 0
+}
+
+// Errno returns the error of the command, or nil if it succeeded.
+func (e *Event) Errno() error {
+	if e.Ret >= 0 {
+		return nil
+	}
+
+	return unix.Errno(-e.Ret)
+}
+
+// Message returns the ethtool options which may issue the command.
+func (e *Event) Message() string {
+	switch e.Type {
+	case EventTypeIoctl:
+		return e.IoctlCmd.Message()
+	case EventTypeGenl:
+		return e.GenlCmd.Message()
+	default:
+		return ""
+	}
+}
+
+// event is the layout of struct event in bpf/ethtool.c.
+type event struct {
+	Type      uint8
+	GenlCmd   uint8
+	IoctlCmd  uint16
+	Pid       uint32
+	Ifname    [16]byte
+	Comm      [16]byte
+	Ret       int32
+	Duration  uint64
+	Verdict   uint8
+	Uid       uint32
+	Gid       uint32
+	LoginUid  uint32
+	SessionID uint32
+	TTY       [16]byte
+	Ifindex   uint32
+	Driver    [32]byte
+	Bus       [32]byte
+	Master    [16]byte
+	Caller    uint64
+	ID        uint64
+	Parent    uint64
+	Op        uint32
+	KStack    int32
+	UStack    int32
+	DataLen   uint16
+}
+
+func nullStr(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+func parseEvent(raw []byte) (*Event, error) {
+	var ev event
+	if err := binary.Read(bytes.NewReader(raw), binary.NativeEndian, &ev); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	e := &Event{
+		Time:     time.Now(),
+		Type:     EventType(ev.Type),
+		IoctlCmd: ethtool.IoctlCmd(ev.IoctlCmd),
+		GenlCmd:  ethtool.GenlCmd(ev.GenlCmd),
+		Ifname:   nullStr(ev.Ifname[:]),
+		Pid:      ev.Pid,
+		Comm:     nullStr(ev.Comm[:]),
+		Ret:      ev.Ret,
+		Duration: time.Duration(ev.Duration),
+		Verdict:  Verdict(ev.Verdict),
+
+		Uid:       ev.Uid,
+		Gid:       ev.Gid,
+		LoginUid:  ev.LoginUid,
+		SessionID: ev.SessionID,
+		TTY:       ttyName(nullStr(ev.TTY[:])),
+
+		Ifindex: ev.Ifindex,
+		Driver:  nullStr(ev.Driver[:]),
+		Bus:     nullStr(ev.Bus[:]),
+		Master:  nullStr(ev.Master[:]),
+		caller:  ev.Caller,
+
+		ID:     ev.ID,
+		Parent: ev.Parent,
+		op:     ev.Op,
+		kstack: ev.KStack,
+		ustack: ev.UStack,
+	}
+
+	// The data copied from the command follows the event.
+	if data := raw[min(binary.Size(ev), len(raw)):]; ev.DataLen != 0 && int(ev.DataLen) <= len(data) {
+		e.data = data[:ev.DataLen]
+	}
+
+	// The genetlink header carries the message sent by the kernel.
+	if e.Type == EventTypeNotify {
+		e.NotifyCmd = ethtool.NotifyCmd(ev.GenlCmd)
+		e.GenlCmd = 0
+	}
+
+	return e, nil
+}
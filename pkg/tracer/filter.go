@@ -0,0 +1,32 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"slices"
+)
+
+// Filter matches the events. An empty field matches all the events.
+type Filter struct {
+	Ifnames []string
+	Pids    []uint32
+	Comms   []string
+}
+
+// Match reports whether the event matches the filter.
+func (f *Filter) Match(ev *Event) bool {
+	if len(f.Ifnames) != 0 && !slices.Contains(f.Ifnames, ev.Ifname) {
+		return false
+	}
+
+	if len(f.Pids) != 0 && !slices.Contains(f.Pids, ev.Pid) {
+		return false
+	}
+
+	if len(f.Comms) != 0 && !slices.Contains(f.Comms, ev.Comm) {
+		return false
+	}
+
+	return true
+}
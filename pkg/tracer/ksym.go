@@ -0,0 +1,78 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ksym struct {
+	addr   uint64
+	name   string
+	module string
+}
+
+// kallsyms are the kernel text symbols sorted by address.
+type kallsyms []ksym
+
+func loadKallsyms() (kallsyms, error) {
+	f, err := os.Open("/proc/kallsyms")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ks kallsyms
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ffffffffc0a1b2c0 t bond_update_speed_duplex	[bonding]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || (fields[1] != "t" && fields[1] != "T") {
+			continue
+		}
+
+		addr, err := strconv.ParseUint(fields[0], 16, 64)
+		if err != nil || addr == 0 {
+			continue
+		}
+
+		sym := ksym{addr: addr, name: fields[2]}
+		if len(fields) > 3 {
+			sym.module = strings.Trim(fields[3], "[]")
+		}
+		ks = append(ks, sym)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ks) == 0 {
+		return nil, fmt.Errorf("no symbol addresses in /proc/kallsyms, check kernel.kptr_restrict")
+	}
+
+	sort.Slice(ks, func(i, j int) bool { return ks[i].addr < ks[j].addr })
+	return ks, nil
+}
+
+// symbolize returns the symbol of addr as "name+0xoff [module]", or the
+// address in hex if it is unknown.
+func (ks kallsyms) symbolize(addr uint64) string {
+	i := sort.Search(len(ks), func(i int) bool { return ks[i].addr > addr }) - 1
+	if i < 0 {
+		return fmt.Sprintf("0x%x", addr)
+	}
+
+	sym := ks[i]
+	s := fmt.Sprintf("%s+0x%x", sym.name, addr-sym.addr)
+	if sym.module != "" {
+		s += " [" + sym.module + "]"
+	}
+
+	return s
+}
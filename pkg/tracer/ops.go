@@ -0,0 +1,301 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"bufio"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/link"
+)
+
+// DriverOp is an ethtool_ops callback of a driver.
+type DriverOp struct {
+	// Member is the member of struct ethtool_ops, e.g. set_ringparam.
+	Member string
+	// Func is the function of the driver, e.g. mlx5e_set_ringparam.
+	Func string
+
+	void bool
+}
+
+// WithDriverOps traces the ethtool_ops callbacks of the kernel module, which
+// are delivered in Event.Ops of the triggering events, or as EventTypeOp
+// events if they are not triggered by a traced event, or the triggering event
+// does not arrive in time.
+func WithDriverOps(module string) Option {
+	return func(o *options) {
+		o.driverOps = module
+	}
+}
+
+// typeShape describes the type for comparing the function prototypes across
+// the kernel and the module BTF.
+func typeShape(typ btf.Type) string {
+	typ = btf.UnderlyingType(typ)
+	switch t := typ.(type) {
+	case *btf.Pointer:
+		return "*" + typeShape(t.Target)
+	case *btf.Void:
+		return "void"
+	case *btf.FuncProto:
+		return "func"
+	case *btf.Array:
+		return "[]" + typeShape(t.Type)
+	default:
+		return fmt.Sprintf("%T %s", typ, typ.TypeName())
+	}
+}
+
+func sameProto(a, b *btf.FuncProto) bool {
+	if len(a.Params) != len(b.Params) || typeShape(a.Return) != typeShape(b.Return) {
+		return false
+	}
+
+	for i := range a.Params {
+		if typeShape(a.Params[i].Type) != typeShape(b.Params[i].Type) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// kcore reads the kernel memory through /proc/kcore.
+type kcore struct {
+	f *os.File
+	e *elf.File
+}
+
+func openKcore() (*kcore, error) {
+	f, err := os.Open("/proc/kcore")
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := elf.NewFile(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to parse /proc/kcore: %w", err)
+	}
+
+	return &kcore{f: f, e: e}, nil
+}
+
+func (k *kcore) read(addr uint64, b []byte) error {
+	for _, p := range k.e.Progs {
+		if p.Type == elf.PT_LOAD && addr >= p.Vaddr && addr+uint64(len(b)) <= p.Vaddr+p.Filesz {
+			_, err := p.ReadAt(b, int64(addr-p.Vaddr))
+			return err
+		}
+	}
+
+	return fmt.Errorf("address 0x%x is not in /proc/kcore", addr)
+}
+
+func (k *kcore) Close() error {
+	return k.f.Close()
+}
+
+// moduleDataSymbols returns the addresses of the data symbols of the module
+// in /proc/kallsyms, which are listed with CONFIG_KALLSYMS_ALL.
+func moduleDataSymbols(module string) ([]uint64, error) {
+	f, err := os.Open("/proc/kallsyms")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var addrs []uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ffffffffc0b3e9a0 d mlx5e_ethtool_ops	[mlx5_core]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || strings.Trim(fields[3], "[]") != module || !strings.ContainsAny(fields[1], "rRdDbB") {
+			continue
+		}
+
+		addr, err := strconv.ParseUint(fields[0], 16, 64)
+		if err == nil && addr != 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs, scanner.Err()
+}
+
+// ResolveDriverOps resolves the ethtool_ops callbacks of the kernel module.
+// The ethtool_ops tables of the module are read through /proc/kcore, and told
+// from the other data symbols of the module by their layout: every callback
+// must point to a kernel function of the same prototype. The callbacks
+// pointing to the functions outside of the module, e.g. ethtool_op_get_link,
+// are left out. The kernel BTF is loaded if kernelTypes is nil.
+func ResolveDriverOps(module string, kernelTypes *btf.Spec) ([]DriverOp, error) {
+	if kernelTypes == nil {
+		var err error
+		if kernelTypes, err = btf.LoadKernelSpec(); err != nil {
+			return nil, fmt.Errorf("failed to load kernel BTF: %w", err)
+		}
+	}
+
+	var ops *btf.Struct
+	if err := kernelTypes.TypeByName("ethtool_ops", &ops); err != nil {
+		return nil, fmt.Errorf("failed to find struct ethtool_ops: %w", err)
+	}
+
+	f, err := os.Open(filepath.Join("/sys/kernel/btf", module))
+	if err != nil {
+		return nil, fmt.Errorf("no BTF of module %s, is it loaded as a module: %w", module, err)
+	}
+	defer f.Close()
+
+	spec, err := btf.LoadSplitSpecFromReader(f, kernelTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load BTF of module %s: %w", module, err)
+	}
+
+	ksyms, err := loadKallsyms()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kallsyms: %w", err)
+	}
+
+	funcs := make(map[uint64]ksym, len(ksyms))
+	for _, sym := range ksyms {
+		funcs[sym.addr] = sym
+	}
+
+	tables, err := moduleDataSymbols(module)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data symbols of module %s: %w", module, err)
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no data symbols of module %s in /proc/kallsyms, is the kernel built with CONFIG_KALLSYMS_ALL", module)
+	}
+
+	core, err := openKcore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open /proc/kcore to read the ethtool_ops of module %s: %w", module, err)
+	}
+	defer core.Close()
+
+	var (
+		result []DriverOp
+		seen   = make(map[string]bool)
+		table  = make([]byte, ops.Size)
+	)
+	for _, addr := range tables {
+		if core.read(addr, table) != nil {
+			continue
+		}
+
+		var (
+			callbacks []DriverOp
+			isTable   = true
+			pointers  int
+		)
+		for _, m := range ops.Members {
+			ptr, ok := m.Type.(*btf.Pointer)
+			if !ok {
+				continue
+			}
+			mproto, ok := ptr.Target.(*btf.FuncProto)
+			if !ok {
+				continue
+			}
+
+			off := int(m.Offset.Bytes())
+			target := binary.NativeEndian.Uint64(table[off : off+8])
+			if target == 0 {
+				continue
+			}
+
+			sym, ok := funcs[target]
+			if !ok {
+				isTable = false
+				break
+			}
+
+			// The prototype is checked if the function is in the BTF.
+			proto := mproto
+			var fn *btf.Func
+			if spec.TypeByName(sym.name, &fn) == nil {
+				if proto, ok = fn.Type.(*btf.FuncProto); !ok || !sameProto(mproto, proto) {
+					isTable = false
+					break
+				}
+			}
+
+			pointers++
+			if sym.module != module {
+				continue
+			}
+
+			_, void := btf.UnderlyingType(proto.Return).(*btf.Void)
+			callbacks = append(callbacks, DriverOp{Member: m.Name, Func: sym.name, void: void})
+		}
+		if !isTable || pointers == 0 {
+			continue
+		}
+
+		// A function may be shared by the tables, e.g. of the PF and the
+		// representors, which is traced once.
+		for _, op := range callbacks {
+			if !seen[op.Func] {
+				seen[op.Func] = true
+				result = append(result, op)
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no ethtool_ops callbacks found in module %s", module)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Func < result[j].Func })
+	return result, nil
+}
+
+func (t *Tracer) attachDriverOps() error {
+	ops, err := ResolveDriverOps(t.opts.driverOps, t.opts.kernelTypes)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, op := range ops {
+		opts := &link.KprobeOptions{Cookie: uint64(i)}
+
+		// Some functions may not be probed, e.g. the ones being inlined
+		// somewhere, which are skipped.
+		kr, err := link.Kretprobe(op.Func, t.obj.KrpEthtoolOp, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", op.Func, err))
+			continue
+		}
+		k, err := link.Kprobe(op.Func, t.obj.KpEthtoolOp, opts)
+		if err != nil {
+			kr.Close()
+			errs = append(errs, fmt.Errorf("%s: %w", op.Func, err))
+			continue
+		}
+
+		t.links = append(t.links, kr, k)
+	}
+
+	if len(errs) == len(ops) {
+		return fmt.Errorf("failed to attach to the ethtool_ops callbacks: %w", errors.Join(errs...))
+	}
+
+	t.ops = ops
+	return nil
+}
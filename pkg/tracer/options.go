@@ -0,0 +1,125 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"github.com/cilium/ebpf/btf"
+)
+
+// AttachMode selects the ways of issuing ethtool commands to trace.
+type AttachMode uint8
+
+const (
+	// AttachIoctl traces the SIOCETHTOOL ioctl.
+	AttachIoctl AttachMode = 1 << iota
+	// AttachGenl traces the ethtool genetlink messages.
+	AttachGenl
+
+	// AttachKernel traces the ethtool functions called by the in-kernel
+	// consumers, e.g. bonding, team and bridge.
+	AttachKernel
+
+	// AttachNotify traces the ethtool genetlink notifications multicast by
+	// the kernel, e.g. ETHTOOL_MSG_FEATURES_NTF.
+	AttachNotify
+
+	// AttachAll traces both the ioctl and the genetlink messages. It does
+	// not include AttachKernel and AttachNotify.
+	AttachAll = AttachIoctl | AttachGenl
+)
+
+// StackMode selects the stacks to capture for the events.
+type StackMode uint8
+
+const (
+	// StackKernel captures the kernel stacks.
+	StackKernel StackMode = 1 << iota
+	// StackUser captures the user stacks.
+	StackUser
+
+	StackBoth = StackKernel | StackUser
+)
+
+// DefaultPerfBufferSize is the default size in bytes of the per-CPU perf
+// event buffer, 64 pages, which holds a few dozens of the events carrying
+// the data of the commands, up to 2048 bytes each.
+const DefaultPerfBufferSize = 64 * 4096
+
+type options struct {
+	filter         Filter
+	mode           AttachMode
+	perfBufferSize int
+	kernelTypes    *btf.Spec
+	lostHandler    func(lost uint64)
+	policy         *Policy
+	driverOps      string
+	stack          StackMode
+	showSecrets    bool
+	eepromChecksum bool
+}
+
+// Option configures the Tracer.
+type Option func(*options)
+
+// WithFilter delivers only the events matching f.
+func WithFilter(f Filter) Option {
+	return func(o *options) {
+		o.filter = f
+	}
+}
+
+// WithAttachMode selects the ways of issuing ethtool commands to trace. It is
+// AttachAll by default.
+func WithAttachMode(mode AttachMode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// WithPerfBufferSize sets the size in bytes of the per-CPU perf event buffer.
+func WithPerfBufferSize(size int) Option {
+	return func(o *options) {
+		o.perfBufferSize = size
+	}
+}
+
+// WithKernelTypes resolves the CO-RE relocations against spec instead of the
+// kernel's own BTF, for kernels without /sys/kernel/btf/vmlinux.
+func WithKernelTypes(spec *btf.Spec) Option {
+	return func(o *options) {
+		o.kernelTypes = spec
+	}
+}
+
+// WithLostHandler calls fn with the number of samples lost because the perf
+// event buffer was full.
+func WithLostHandler(fn func(lost uint64)) Option {
+	return func(o *options) {
+		o.lostHandler = fn
+	}
+}
+
+// WithStack captures the stacks of the events, which are symbolized into
+// Event.KernelStack and Event.UserStack.
+func WithStack(mode StackMode) Option {
+	return func(o *options) {
+		o.stack = mode
+	}
+}
+
+// WithShowSecrets shows the RSS hash key in Event.Details, which is hashed by
+// default. The passwords are never shown.
+func WithShowSecrets(show bool) Option {
+	return func(o *options) {
+		o.showSecrets = show
+	}
+}
+
+// WithEepromChecksum shows the prefix of the SHA-256 of the bytes written to
+// the EEPROM in Event.Details.
+func WithEepromChecksum(checksum bool) Option {
+	return func(o *options) {
+		o.eepromChecksum = checksum
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/tklauser/ps"
+)
+
+// containerIDRe matches the container id in the cgroup path of docker,
+// containerd, cri-o and podman, e.g. docker-<id>.scope or cri-containerd-<id>.
+var containerIDRe = regexp.MustCompile(`[0-9a-f]{64}`)
+
+func processName(pid int, comm string) string {
+	p, err := ps.FindProcess(pid)
+	if err != nil {
+		return comm
+	}
+
+	if p.Command() == "ethtool" {
+		process := processName(p.PPID(), comm)
+		return fmt.Sprintf("ethtool(parent %d:%s)", p.PPID(), process)
+	}
+
+	return p.Command()
+}
+
+func containerID(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return ""
+	}
+
+	ids := containerIDRe.FindAll(data, -1)
+	if len(ids) == 0 {
+		return ""
+	}
+
+	return string(ids[len(ids)-1][:12])
+}
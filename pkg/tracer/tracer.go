@@ -0,0 +1,568 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tracer traces the ethtool commands issued through the SIOCETHTOOL
+// ioctl and the ethtool genetlink messages with bpf.
+//
+// On kernels before 5.11, the caller is expected to remove the memlock rlimit,
+// e.g. by github.com/cilium/ebpf/rlimit.RemoveMemlock(), before New().
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sys/unix"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+)
+
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -cc clang -no-strip -no-global-types -target amd64,arm64,riscv64,s390x,ppc64le ethtool ../../bpf/ethtool.c -- -I../../bpf/headers
+
+// KernelSymbols are the kernel functions the tracer attaches to.
+var KernelSymbols = []string{
+	"dev_ethtool",
+	"__dev_get_by_name",
+	"genl_rcv_msg",
+	"ethnl_parse_header_dev_get",
+}
+
+// KernelConsumerSymbols are the kernel functions the tracer attaches to with
+// AttachKernel.
+var KernelConsumerSymbols = []string{
+	"__ethtool_get_link_ksettings",
+	"__ethtool_get_ts_info",
+}
+
+// NotifySymbols are the kernel functions the tracer attaches to with
+// AttachNotify. netlink_unicast is attached with AttachGenl as well, to tell
+// the results of the module firmware flashes.
+var NotifySymbols = []string{
+	"ethnl_multicast",
+	"netlink_unicast",
+}
+
+// Tracer traces the ethtool commands.
+type Tracer struct {
+	opts   options
+	filter atomic.Pointer[Filter]
+
+	obj     ethtoolObjects
+	enforce enforceObjects
+	links   []link.Link
+	reader  *perf.Reader
+	users   userCache
+	ksyms   kallsyms
+	usyms   userSymbols
+	decoder ethtool.Decoder
+
+	// ops are the attached ethtool_ops callbacks indexed by the cookie, and
+	// pending are their events waiting for the parent events.
+	ops     []DriverOp
+	pending map[uint64][]*Event
+
+	// flashes are when the module firmware flashes started, indexed by the
+	// ifindex.
+	flashes map[uint32]time.Time
+}
+
+// New loads the bpf objects and attaches them to the kernel.
+func New(opts ...Option) (*Tracer, error) {
+	t := &Tracer{
+		opts: options{
+			mode:           AttachAll,
+			perfBufferSize: DefaultPerfBufferSize,
+		},
+		users:   make(userCache),
+		usyms:   make(userSymbols),
+		pending: make(map[uint64][]*Event),
+		flashes: make(map[uint32]time.Time),
+	}
+	for _, opt := range opts {
+		opt(&t.opts)
+	}
+	t.SetFilter(t.opts.filter)
+
+	spec, err := loadEthtool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spec: %w", err)
+	}
+
+	// The family id is 0 if the kernel is built without
+	// CONFIG_ETHTOOL_NETLINK, and ethtool falls back to ioctl. There is no
+	// notification either.
+	familyID, err := ethtool.FamilyID()
+	if err != nil {
+		if t.opts.mode&^(AttachGenl|AttachNotify) == 0 {
+			return nil, fmt.Errorf("failed to get ethtool genetlink family: %w", err)
+		}
+		t.opts.mode &^= AttachGenl | AttachNotify
+	}
+
+	if err := t.rewriteSpec(spec, familyID); err != nil {
+		return nil, err
+	}
+
+	// The features and the link modes are decoded by their bits without
+	// their names, e.g. if there is no loopback device in the network
+	// namespace. Read them before attaching, so that the reading is not
+	// traced.
+	t.decoder.Features, _ = ethtool.FeatureNames()
+	t.decoder.LinkModes, _ = ethtool.LinkModeNames()
+	t.decoder.ShowSecrets = t.opts.showSecrets
+	t.decoder.EepromChecksum = t.opts.eepromChecksum
+
+	if err := spec.LoadAndAssign(&t.obj, &ebpf.CollectionOptions{
+		Programs: ebpf.ProgramOptions{
+			KernelTypes: t.opts.kernelTypes,
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to load objects: %w", err)
+	}
+
+	if t.opts.mode&AttachKernel != 0 || t.opts.stack&StackKernel != 0 {
+		if t.ksyms, err = loadKallsyms(); err != nil {
+			t.Close()
+			return nil, fmt.Errorf("failed to load kallsyms: %w", err)
+		}
+	}
+
+	if err := t.attach(); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	if t.opts.driverOps != "" {
+		if err := t.attachDriverOps(); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+
+	if t.opts.policy != nil {
+		if err := t.loadEnforce(familyID); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+
+	reader, err := perf.NewReader(t.obj.Events, t.opts.perfBufferSize)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to create perf event reader: %w", err)
+	}
+	t.reader = reader
+
+	return t, nil
+}
+
+// rewriteSpec rewrites the constants shared by the bpf objects, and shrinks the
+// stacks map if no stack is captured.
+func (t *Tracer) rewriteSpec(spec *ebpf.CollectionSpec, familyID uint16) error {
+	if err := spec.RewriteConstants(map[string]interface{}{
+		"ethtool_family_id": familyID,
+		"stack_mode":        uint8(t.opts.stack),
+	}); err != nil {
+		return fmt.Errorf("failed to rewrite constants: %w", err)
+	}
+
+	if t.opts.stack == 0 {
+		spec.Maps["stacks"].MaxEntries = 1
+	}
+
+	return nil
+}
+
+func (t *Tracer) attach() error {
+	kprobe := func(symbol string, prog *ebpf.Program, ret bool) error {
+		var (
+			l   link.Link
+			err error
+		)
+		if ret {
+			l, err = link.Kretprobe(symbol, prog, nil)
+		} else {
+			l, err = link.Kprobe(symbol, prog, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to attach to %s: %w", symbol, err)
+		}
+
+		t.links = append(t.links, l)
+		return nil
+	}
+
+	// The kretprobes are attached before the kprobes, so that no request is
+	// left behind in the requests map.
+	if t.opts.mode&AttachIoctl != 0 {
+		if err := kprobe("dev_ethtool", t.obj.KrpDevEthtool, true); err != nil {
+			return err
+		}
+		if err := kprobe("__dev_get_by_name", t.obj.KrpDevGetByName, true); err != nil {
+			return err
+		}
+		if err := kprobe("dev_ethtool", t.obj.KpDevEthtool, false); err != nil {
+			return err
+		}
+	}
+
+	if t.opts.mode&AttachGenl != 0 {
+		if err := kprobe("genl_rcv_msg", t.obj.KrpGenlRcvMsg, true); err != nil {
+			return err
+		}
+		if err := kprobe("ethnl_parse_header_dev_get", t.obj.KrpEthnlDev, true); err != nil {
+			return err
+		}
+		if err := kprobe("ethnl_parse_header_dev_get", t.obj.KpEthnlDev, false); err != nil {
+			return err
+		}
+		if err := kprobe("genl_rcv_msg", t.obj.KpGenlRcvMsg, false); err != nil {
+			return err
+		}
+	}
+
+	if t.opts.mode&AttachKernel != 0 {
+		if err := kprobe("__ethtool_get_link_ksettings", t.obj.KrpGetLinkKsettings, true); err != nil {
+			return err
+		}
+		if err := kprobe("__ethtool_get_link_ksettings", t.obj.KpGetLinkKsettings, false); err != nil {
+			return err
+		}
+		if err := kprobe("__ethtool_get_ts_info", t.obj.KrpGetTsInfo, true); err != nil {
+			return err
+		}
+		if err := kprobe("__ethtool_get_ts_info", t.obj.KpGetTsInfo, false); err != nil {
+			return err
+		}
+	}
+
+	if t.opts.mode&AttachNotify != 0 {
+		if err := kprobe("ethnl_multicast", t.obj.KpEthnlMulticast, false); err != nil {
+			return err
+		}
+	}
+
+	// The module firmware is flashed in the background, and only the
+	// notifications unicast to the requester tell its result.
+	if t.opts.mode&(AttachGenl|AttachNotify) != 0 {
+		if err := kprobe("netlink_unicast", t.obj.KpNetlinkUnicast, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetFilter replaces the filter, which takes effect on the next event without
+// detaching the bpf programs. It is safe to call concurrently with Run.
+func (t *Tracer) SetFilter(f Filter) {
+	t.filter.Store(&f)
+}
+
+// Run delivers the traced events to fn until ctx is done or an error occurs.
+// fn is called in the same goroutine, one event at a time.
+func (t *Tracer) Run(ctx context.Context, fn func(*Event)) error {
+	errg, ctx := errgroup.WithContext(ctx)
+
+	errg.Go(func() error {
+		<-ctx.Done()
+		_ = t.reader.Close()
+		return nil
+	})
+
+	errg.Go(func() error {
+		return t.readEvents(ctx, fn)
+	})
+
+	return errg.Wait()
+}
+
+func (t *Tracer) readEvents(ctx context.Context, fn func(*Event)) error {
+	for {
+		// Wake up to flush the orphaned ethtool_ops events even if no
+		// more event arrives.
+		var deadline time.Time
+		if len(t.pending) != 0 {
+			deadline = time.Now().Add(opTimeout)
+		}
+		t.reader.SetDeadline(deadline)
+
+		record, err := t.reader.Read()
+		if err != nil {
+			if errors.Is(err, perf.ErrClosed) {
+				return nil
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				t.flushOps(time.Now().Add(-opTimeout), fn)
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
+				return fmt.Errorf("failed to read record: %w", err)
+			}
+		}
+
+		if record.LostSamples != 0 && t.opts.lostHandler != nil {
+			t.opts.lostHandler(record.LostSamples)
+		}
+
+		if len(record.RawSample) != 0 {
+			ev, err := parseEvent(record.RawSample)
+			if err != nil {
+				return err
+			}
+
+			if ev = t.nest(ev); ev != nil {
+				t.deliver(ev, fn)
+			}
+
+			before := time.Now().Add(-opTimeout)
+			if len(t.pending) >= maxPendingOps {
+				before = time.Now()
+			}
+			t.flushOps(before, fn)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// deliver passes the event to fn if it matches the filter. Without
+// AttachNotify, only the notifications ending the module firmware flashes are
+// delivered, which carry their results.
+func (t *Tracer) deliver(ev *Event, fn func(*Event)) {
+	// The unicast notifications are emitted without the device, which their
+	// request header identifies.
+	if ev.Type == EventTypeNotify && ev.Ifindex == 0 {
+		if h, ok := ethtool.ParseHeader(ev.data); ok {
+			ev.Ifindex, ev.Ifname = h.DevIndex, h.DevName
+		}
+	}
+
+	t.trackFlash(ev)
+	if ev.Type == EventTypeNotify && t.opts.mode&AttachNotify == 0 && !ethtool.ModuleFlashEnded(ev.data) {
+		return
+	}
+
+	matched := t.filter.Load().Match(ev)
+	t.stacks(ev, matched)
+	if matched {
+		t.complete(ev)
+		fn(ev)
+	}
+}
+
+// sameIfname reports whether name is ifname, which is truncated to
+// IFNAMSIZ-1 bytes by the bpf programs.
+func sameIfname(name, ifname string) bool {
+	if len(ifname) == unix.IFNAMSIZ-1 {
+		return strings.HasPrefix(name, ifname)
+	}
+
+	return name == ifname
+}
+
+func (t *Tracer) complete(ev *Event) {
+	ev.Process = processName(int(ev.Pid), ev.Comm)
+	ev.Container = containerID(int(ev.Pid))
+	ev.User = t.users.name(ev.Uid)
+	ev.LoginUser = t.users.name(ev.LoginUid)
+	if ev.Type == EventTypeKernel && ev.caller != 0 {
+		ev.Caller = t.ksyms.symbolize(ev.caller)
+	}
+	switch ev.Type {
+	case EventTypeIoctl:
+		ev.Details = t.decoder.Ioctl(ev.IoctlCmd, ev.data)
+	case EventTypeGenl:
+		ev.Details = t.decoder.Genl(ev.GenlCmd, ev.data)
+		if h, ok := ethtool.ParseHeader(ev.data); ok {
+			ev.HeaderFlags = h.FlagNames()
+			switch {
+			case h.DevIndex != 0:
+				ev.DevBy = DevByIndex
+			case h.HasDevName && h.DevName != "" && ev.Ifname != "" && !sameIfname(h.DevName, ev.Ifname):
+				ev.DevBy, ev.Altname = DevByAltname, h.DevName
+			case h.HasDevName && h.DevName != "":
+				ev.DevBy = DevByName
+			}
+		}
+	case EventTypeNotify:
+		ev.Details = t.decoder.Notify(ev.NotifyCmd, ev.data)
+		if ev.Duration != 0 {
+			ev.Details += fmt.Sprintf(" after %s", ev.Duration.Round(time.Millisecond))
+		}
+	}
+	if ev.Type == EventTypeOp && int(ev.op) < len(t.ops) {
+		op := t.ops[ev.op]
+		ev.Op, ev.Func = op.Member, op.Func
+		if op.void {
+			ev.Ret = 0
+		}
+	}
+
+	for _, op := range ev.Ops {
+		t.complete(op)
+	}
+}
+
+// maxStackDepth is MAX_STACK_DEPTH in bpf/event.h.
+const maxStackDepth = 127
+
+// stacks looks up the stacks of the event and its ethtool_ops callbacks in the
+// stacks map, and symbolizes them if symbolize is set. A stack is shared by
+// the events with the same frames, e.g. the user stacks of the callbacks and
+// the command, so it is left in the map, whose buckets are reused by the
+// later stacks.
+func (t *Tracer) stacks(ev *Event, symbolize bool) {
+	if t.opts.stack == 0 {
+		return
+	}
+
+	taken := make(map[int32][]uint64)
+	take := func(id int32) []uint64 {
+		if id < 0 {
+			return nil
+		}
+		if stack, ok := taken[id]; ok {
+			return stack
+		}
+
+		var (
+			frames [maxStackDepth]uint64
+			stack  []uint64
+		)
+		if err := t.obj.Stacks.Lookup(uint32(id), &frames); err == nil {
+			for _, addr := range frames {
+				if addr == 0 {
+					break
+				}
+				stack = append(stack, addr)
+			}
+		}
+
+		taken[id] = stack
+		return stack
+	}
+
+	for _, e := range append([]*Event{ev}, ev.Ops...) {
+		kstack, ustack := take(e.kstack), take(e.ustack)
+		if !symbolize {
+			continue
+		}
+
+		for _, addr := range kstack {
+			e.KernelStack = append(e.KernelStack, t.ksyms.symbolize(addr))
+		}
+		if len(ustack) != 0 {
+			e.UserStack = t.usyms.symbolize(e.Pid, ustack)
+		}
+	}
+}
+
+// The ethtool_ops events wait for their parents, which may never arrive if
+// the parent events are lost, or are read before the callbacks from the perf
+// buffer of another CPU. They are delivered as standalone EventTypeOp events
+// after opTimeout, or once maxPendingOps parents are waited for.
+const (
+	opTimeout     = 5 * time.Second
+	maxPendingOps = 4096
+)
+
+// flashTimeout bounds how long a module firmware flash is waited for, which
+// takes minutes.
+const flashTimeout = time.Hour
+
+// trackFlash measures the module firmware flash, from the request to the
+// notification of its completion or failure, as the flash runs in the
+// background of the kernel. It tracks the events regardless of the filter.
+// The flashes whose notification is lost are forgotten after flashTimeout.
+func (t *Tracer) trackFlash(ev *Event) {
+	switch {
+	case ev.Type == EventTypeGenl && ev.GenlCmd == ethtool.ETHTOOL_MSG_MODULE_FW_FLASH_ACT:
+		for ifindex, start := range t.flashes {
+			if ev.Time.Sub(start) > flashTimeout {
+				delete(t.flashes, ifindex)
+			}
+		}
+		if ev.Ret == 0 {
+			t.flashes[ev.Ifindex] = ev.Time
+		}
+	case ev.Type == EventTypeNotify && ev.NotifyCmd == ethtool.ETHTOOL_MSG_MODULE_FW_FLASH_NTF:
+		if !ethtool.ModuleFlashEnded(ev.data) {
+			return
+		}
+		if start, ok := t.flashes[ev.Ifindex]; ok {
+			ev.Duration = ev.Time.Sub(start)
+			delete(t.flashes, ev.Ifindex)
+		}
+	}
+}
+
+// nest holds the ethtool_ops events until their parent events arrive, which
+// are emitted when the commands return, after the callbacks. It returns nil
+// if the event is held.
+func (t *Tracer) nest(ev *Event) *Event {
+	if ev.Type == EventTypeOp && ev.Parent != 0 {
+		t.pending[ev.Parent] = append(t.pending[ev.Parent], ev)
+		return nil
+	}
+
+	if ops, ok := t.pending[ev.ID]; ok && ev.ID != 0 {
+		ev.Ops = ops
+		delete(t.pending, ev.ID)
+	}
+
+	return ev
+}
+
+// flushOps delivers the ethtool_ops events waiting since before as standalone
+// events, in the order of their arrival.
+func (t *Tracer) flushOps(before time.Time, fn func(*Event)) {
+	var orphans []*Event
+	for parent, ops := range t.pending {
+		if ops[0].Time.After(before) {
+			continue
+		}
+
+		orphans = append(orphans, ops...)
+		delete(t.pending, parent)
+	}
+
+	sort.SliceStable(orphans, func(i, j int) bool {
+		return orphans[i].Time.Before(orphans[j].Time)
+	})
+	for _, ev := range orphans {
+		t.deliver(ev, fn)
+	}
+}
+
+// Close detaches the bpf programs and releases the resources.
+func (t *Tracer) Close() error {
+	if t.reader != nil {
+		_ = t.reader.Close()
+	}
+
+	for i := len(t.links) - 1; i >= 0; i-- {
+		_ = t.links[i].Close()
+	}
+	t.links = nil
+
+	_ = t.enforce.Close()
+	return t.obj.Close()
+}
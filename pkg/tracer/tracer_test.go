@@ -0,0 +1,25 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import "testing"
+
+func TestSameIfname(t *testing.T) {
+	for _, tt := range []struct {
+		name, ifname string
+		same         bool
+	}{
+		{"eth0", "eth0", true},
+		{"uplink", "eth0", false},
+		{"eth0", "eth", false},
+		// The names of 15 bytes may be the truncated ones.
+		{"enp0s20f0u1u2c2", "enp0s20f0u1u2c2", true},
+		{"enp0s20f0u1u2c2-long", "enp0s20f0u1u2c2", true},
+		{"enp0s20f0u1u2-long", "enp0s20f0u1u2", false},
+	} {
+		if got := sameIfname(tt.name, tt.ifname); got != tt.same {
+			t.Errorf("sameIfname(%q, %q) = %v, want %v", tt.name, tt.ifname, got, tt.same)
+		}
+	}
+}
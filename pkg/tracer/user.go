@@ -0,0 +1,51 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ttyName converts the kernel name of the tty to the one shown by ps and w,
+// e.g. pts3 to pts/3.
+func ttyName(name string) string {
+	if n, ok := strings.CutPrefix(name, "pts"); ok && n != "" {
+		return "pts/" + n
+	}
+
+	return name
+}
+
+const userCacheTTL = time.Minute
+
+type userEntry struct {
+	name    string
+	expires time.Time
+}
+
+// userCache resolves the uids to the user names, caching them for a while as
+// the lookup may read /etc/passwd every time.
+type userCache map[uint32]userEntry
+
+func (c userCache) name(uid uint32) string {
+	if uid == NoLoginUid {
+		return ""
+	}
+
+	now := time.Now()
+	if e, ok := c[uid]; ok && now.Before(e.expires) {
+		return e.name
+	}
+
+	var name string
+	if u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+		name = u.Username
+	}
+
+	c[uid] = userEntry{name: name, expires: now.Add(userCacheTTL)}
+	return name
+}
@@ -0,0 +1,298 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package tracer
+
+import (
+	"bufio"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// debugDir is where the separate debug info files are looked up by build id,
+// e.g. /usr/lib/debug/.build-id/ab/cdef.debug.
+const debugDir = "/usr/lib/debug/.build-id"
+
+// maxELFFiles bounds the cached ELF files.
+const maxELFFiles = 64
+
+// mapping is a file-backed memory mapping of a process.
+type mapping struct {
+	start, end, off uint64
+	path            string
+}
+
+// readMaps reads the file-backed mappings of the process.
+func readMaps(pid uint32) ([]mapping, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var maps []mapping
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 7f2c1a400000-7f2c1a428000 r-xp 00028000 fd:01 1234 /usr/lib/libc.so.6
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+
+		start, end, _ := strings.Cut(fields[0], "-")
+		var m mapping
+		m.start, _ = strconv.ParseUint(start, 16, 64)
+		m.end, _ = strconv.ParseUint(end, 16, 64)
+		m.off, _ = strconv.ParseUint(fields[2], 16, 64)
+		m.path = fields[5]
+		maps = append(maps, m)
+	}
+
+	return maps, scanner.Err()
+}
+
+type elfSym struct {
+	addr, size uint64
+	name       string
+}
+
+type cuRange struct {
+	low, high uint64
+	cu        *dwarf.Entry
+}
+
+// elfFile is the symbols and the line info of an ELF file.
+type elfFile struct {
+	loads []*elf.Prog
+	syms  []elfSym
+	dwarf *dwarf.Data
+	cus   []cuRange
+}
+
+func funcSymbols(f *elf.File) []elfSym {
+	var syms []elfSym
+	for _, load := range []func() ([]elf.Symbol, error){f.Symbols, f.DynamicSymbols} {
+		all, _ := load()
+		for _, s := range all {
+			if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value != 0 {
+				syms = append(syms, elfSym{addr: s.Value, size: s.Size, name: s.Name})
+			}
+		}
+	}
+
+	return syms
+}
+
+func buildID(f *elf.File) string {
+	sec := f.Section(".note.gnu.build-id")
+	if sec == nil {
+		return ""
+	}
+
+	data, err := sec.Data()
+	// namesz, descsz, type and "GNU\0" precede the id.
+	if err != nil || len(data) <= 16 {
+		return ""
+	}
+
+	return hex.EncodeToString(data[16:])
+}
+
+// openDebugFile opens the separate debug info file of f, if any.
+func openDebugFile(f *elf.File) *elf.File {
+	id := buildID(f)
+	if len(id) < 3 {
+		return nil
+	}
+
+	df, err := elf.Open(filepath.Join(debugDir, id[:2], id[2:]+".debug"))
+	if err != nil {
+		return nil
+	}
+
+	return df
+}
+
+func loadELFFile(path string) (*elfFile, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ef := &elfFile{syms: funcSymbols(f)}
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD {
+			ef.loads = append(ef.loads, p)
+		}
+	}
+
+	// The stripped files may have the symbols and the line info in the
+	// separate debug info files.
+	dbg := f
+	if f.Section(".debug_info") == nil {
+		if df := openDebugFile(f); df != nil {
+			defer df.Close()
+			ef.syms = append(ef.syms, funcSymbols(df)...)
+			dbg = df
+		}
+	}
+
+	if d, err := dbg.DWARF(); err == nil {
+		ef.dwarf = d
+		r := d.Reader()
+		for {
+			e, err := r.Next()
+			if err != nil || e == nil {
+				break
+			}
+			if e.Tag == dwarf.TagCompileUnit {
+				ranges, _ := d.Ranges(e)
+				for _, rg := range ranges {
+					ef.cus = append(ef.cus, cuRange{low: rg[0], high: rg[1], cu: e})
+				}
+			}
+			r.SkipChildren()
+		}
+	}
+
+	sort.Slice(ef.syms, func(i, j int) bool { return ef.syms[i].addr < ef.syms[j].addr })
+	return ef, nil
+}
+
+// vaddr translates the offset in the file to the virtual address in the ELF
+// file, which the symbols and the line info are based on.
+func (ef *elfFile) vaddr(off uint64) (uint64, bool) {
+	for _, p := range ef.loads {
+		if off >= p.Off && off < p.Off+p.Filesz {
+			return off - p.Off + p.Vaddr, true
+		}
+	}
+
+	return 0, false
+}
+
+func (ef *elfFile) symbol(addr uint64) (elfSym, bool) {
+	i := sort.Search(len(ef.syms), func(i int) bool { return ef.syms[i].addr > addr }) - 1
+	if i < 0 || (ef.syms[i].size != 0 && addr >= ef.syms[i].addr+ef.syms[i].size) {
+		return elfSym{}, false
+	}
+
+	return ef.syms[i], true
+}
+
+// line returns the source line of addr as "file:line", or empty if there is
+// no line info.
+func (ef *elfFile) line(addr uint64) string {
+	for _, rg := range ef.cus {
+		if addr < rg.low || addr >= rg.high {
+			continue
+		}
+
+		lr, err := ef.dwarf.LineReader(rg.cu)
+		if err != nil || lr == nil {
+			return ""
+		}
+
+		var le dwarf.LineEntry
+		if err := lr.SeekPC(addr, &le); err != nil || le.File == nil {
+			return ""
+		}
+
+		return fmt.Sprintf("%s:%d", filepath.Base(le.File.Name), le.Line)
+	}
+
+	return ""
+}
+
+type fileKey struct {
+	dev, ino uint64
+}
+
+// userSymbols symbolizes the user stacks by the ELF symbols and the DWARF
+// line info of the files mapped by the processes.
+type userSymbols map[fileKey]*elfFile
+
+func (us userSymbols) file(path string) (*elfFile, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return nil, err
+	}
+
+	key := fileKey{dev: uint64(st.Dev), ino: st.Ino}
+	if ef, ok := us[key]; ok {
+		return ef, nil
+	}
+
+	ef, err := loadELFFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(us) >= maxELFFiles {
+		clear(us)
+	}
+	us[key] = ef
+
+	return ef, nil
+}
+
+// symbolize returns the frames of the stack of the process as
+// "name+0xoff (file:line) [file]", or the address in hex with the file if
+// the symbol is unknown. The frames are addresses only if the process has
+// exited.
+func (us userSymbols) symbolize(pid uint32, stack []uint64) []string {
+	maps, _ := readMaps(pid)
+
+	frames := make([]string, 0, len(stack))
+	for _, addr := range stack {
+		i := sort.Search(len(maps), func(i int) bool { return maps[i].end > addr })
+		if i == len(maps) || addr < maps[i].start {
+			frames = append(frames, fmt.Sprintf("0x%x", addr))
+			continue
+		}
+
+		frames = append(frames, us.symbolizeIn(pid, maps[i], addr))
+	}
+
+	return frames
+}
+
+func (us userSymbols) symbolizeIn(pid uint32, m mapping, addr uint64) string {
+	suffix := " [" + filepath.Base(m.path) + "]"
+
+	// The path is in the mount namespace of the process.
+	ef, err := us.file(fmt.Sprintf("/proc/%d/root%s", pid, m.path))
+	if err != nil {
+		return fmt.Sprintf("0x%x", addr) + suffix
+	}
+
+	vaddr, ok := ef.vaddr(addr - m.start + m.off)
+	if !ok {
+		return fmt.Sprintf("0x%x", addr) + suffix
+	}
+
+	sym, ok := ef.symbol(vaddr)
+	if !ok {
+		return fmt.Sprintf("0x%x", addr) + suffix
+	}
+
+	s := fmt.Sprintf("%s+0x%x", sym.name, vaddr-sym.addr)
+	if ef.dwarf != nil {
+		// The return address points to the instruction after the call.
+		if line := ef.line(vaddr - 1); line != "" {
+			s += " (" + line + ")"
+		}
+	}
+
+	return s + suffix
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+// The record file is JSON lines: the first line is the recordHeader, and each
+// following line is a tracer.Event. Version 2 adds the user identity.
+const (
+	recordFormat  = "ethtoolsnoop-record"
+	recordVersion = 2
+)
+
+type recordInterface struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	MAC   string `json:"mac,omitempty"`
+}
+
+type recordHeader struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	Time       time.Time         `json:"time"`
+	Hostname   string            `json:"hostname"`
+	Kernel     string            `json:"kernel"`
+	Interfaces []recordInterface `json:"interfaces"`
+}
+
+func newRecordHeader() recordHeader {
+	hdr := recordHeader{
+		Format:  recordFormat,
+		Version: recordVersion,
+		Time:    time.Now(),
+		Kernel:  kernelRelease(),
+	}
+
+	hdr.Hostname, _ = os.Hostname()
+
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		hdr.Interfaces = append(hdr.Interfaces, recordInterface{
+			Index: iface.Index,
+			Name:  iface.Name,
+			MAC:   iface.HardwareAddr.String(),
+		})
+	}
+
+	return hdr
+}
+
+func runRecord(ctx context.Context) error {
+	if flags.recordFile == "" {
+		return errors.New("no file to record to, specify it by -o")
+	}
+
+	t, err := newTracer()
+	if err != nil {
+		return fmt.Errorf("failed to create tracer: %w", err)
+	}
+	defer t.Close()
+
+	f, err := os.Create(flags.recordFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	if err := enc.Encode(newRecordHeader()); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	n := 0
+	err = t.Run(ctx, func(ev *tracer.Event) {
+		if err := enc.Encode(ev); err != nil {
+			log.Printf("Failed to record event: %s", err)
+			return
+		}
+
+		n++
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Recorded %d events to %s", n, flags.recordFile)
+	return nil
+}
+
+func runReplay(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+
+	var hdr recordHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if hdr.Format != recordFormat {
+		return fmt.Errorf("%s is not a record file of ethtoolsnoop", path)
+	}
+	if hdr.Version > recordVersion {
+		return fmt.Errorf("unsupported record version %d, the latest supported one is %d", hdr.Version, recordVersion)
+	}
+
+	log.Printf("Replaying the record of %s (kernel %s) at %s", hdr.Hostname, hdr.Kernel, hdr.Time.Format(time.RFC3339))
+
+	sinks, err := newMultiSink(parseOutputs(flags.outputs))
+	if err != nil {
+		return fmt.Errorf("failed to create outputs: %w", err)
+	}
+	defer sinks.Close()
+
+	rs, err := flagRules()
+	if err != nil {
+		return fmt.Errorf("failed to load rules: %w", err)
+	}
+	defer waitActions()
+
+	flt := filter()
+	for {
+		var ev tracer.Event
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read event: %w", err)
+		}
+
+		if hdr.Version < 2 {
+			ev.LoginUid, ev.SessionID = tracer.NoLoginUid, tracer.NoLoginUid
+		}
+
+		if !flt.Match(&ev) {
+			continue
+		}
+
+		if err := sinks.write(&ev); err != nil {
+			return fmt.Errorf("failed to output event: %w", err)
+		}
+		rs.fire(&ev)
+	}
+}
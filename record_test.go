@@ -0,0 +1,118 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+// writeRecord writes the record file of the header and the events.
+func writeRecord(t *testing.T, hdr recordHeader, events ...*tracer.Event) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(hdr); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "trace.ets")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// replay replays the record file with the filter flags, and returns the
+// events output as JSON lines.
+func replay(t *testing.T, path string, ifnames ...string) []map[string]any {
+	t.Helper()
+
+	saved := flags
+	t.Cleanup(func() { flags = saved })
+
+	out := filepath.Join(t.TempDir(), "events.json")
+	flags.outputs = []string{"json:" + out}
+	flags.ifnames = ifnames
+
+	if err := runReplay(path); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []map[string]any
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var ev map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+
+	return events
+}
+
+func TestReplay(t *testing.T) {
+	hdr := newRecordHeader()
+	path := writeRecord(t, hdr,
+		&tracer.Event{Type: tracer.EventTypeIoctl, Ifname: "eth0", LoginUid: 1000, SessionID: 3},
+		&tracer.Event{Type: tracer.EventTypeIoctl, Ifname: "eth1", LoginUid: 1000, SessionID: 3},
+	)
+
+	events := replay(t, path, "eth0")
+	if len(events) != 1 || events[0]["ifname"] != "eth0" {
+		t.Fatalf("replayed %v, want only the event of eth0", events)
+	}
+	if events[0]["loginuid"] != 1000.0 || events[0]["sessionid"] != 3.0 {
+		t.Errorf("replayed %v, want loginuid 1000 and sessionid 3", events[0])
+	}
+}
+
+// The records of version 1 have no user identity, which is unset rather than
+// root.
+func TestReplayVersion1(t *testing.T) {
+	hdr := newRecordHeader()
+	hdr.Version = 1
+	path := writeRecord(t, hdr, &tracer.Event{Type: tracer.EventTypeIoctl, Ifname: "eth0"})
+
+	events := replay(t, path)
+	if len(events) != 1 {
+		t.Fatalf("replayed %d events, want 1", len(events))
+	}
+	if events[0]["loginuid"] != -1.0 || events[0]["sessionid"] != -1.0 {
+		t.Errorf("replayed %v, want loginuid and sessionid unset", events[0])
+	}
+}
+
+func TestReplayInvalid(t *testing.T) {
+	newer := newRecordHeader()
+	newer.Version = recordVersion + 1
+
+	other := newRecordHeader()
+	other.Format = "pcap"
+
+	for _, hdr := range []recordHeader{newer, other} {
+		if err := runReplay(writeRecord(t, hdr)); err == nil {
+			t.Errorf("runReplay() of %s version %d succeeded, want an error", hdr.Format, hdr.Version)
+		}
+	}
+}
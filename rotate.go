@@ -0,0 +1,113 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// rotateConfig configures the rotation of an output file.
+type rotateConfig struct {
+	// MaxSize is the size in bytes to rotate the file at.
+	MaxSize int64 `yaml:"max_size"`
+	// MaxBackups is the number of rotated files to keep, as <path>.1 to
+	// <path>.<MaxBackups>.
+	MaxBackups int `yaml:"max_backups"`
+}
+
+// rotatingFile is an append-only file which is rotated when it grows beyond
+// the max size.
+type rotatingFile struct {
+	path string
+	cfg  rotateConfig
+	f    *os.File
+	size int64
+
+	// header is written at the top of every new file, e.g. the CSV header.
+	header []byte
+}
+
+func openRotatingFile(path string, cfg rotateConfig) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, cfg: cfg}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.f, r.size = f, fi.Size()
+	return r.writeHeader()
+}
+
+// setHeader sets the header, which is written at once if the file is empty.
+func (r *rotatingFile) setHeader(header []byte) error {
+	r.header = header
+	return r.writeHeader()
+}
+
+func (r *rotatingFile) writeHeader() error {
+	if r.size != 0 || len(r.header) == 0 {
+		return nil
+	}
+
+	n, err := r.f.Write(r.header)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+
+	if r.cfg.MaxBackups <= 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return r.open()
+	}
+
+	for i := r.cfg.MaxBackups - 1; i > 0; i-- {
+		old := fmt.Sprintf("%s.%d", r.path, i)
+		if err := os.Rename(old, fmt.Sprintf("%s.%d", r.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(p)) > r.cfg.MaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("failed to rotate %s: %w", r.path, err)
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeLines writes the lines to the rotating file one by one.
+func writeLines(t *testing.T, r *rotatingFile, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fileContent returns the content of the file, or "<missing>" if it does not
+// exist.
+func fileContent(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "<missing>"
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	r, err := openRotatingFile(path, rotateConfig{MaxSize: 8, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// A line longer than the max size is not split, and the oldest backup
+	// is dropped.
+	writeLines(t, r, "1111\n", "2222\n", "3333\n", "44444444444\n", "5555\n")
+
+	for _, tt := range []struct {
+		path string
+		want string
+	}{
+		{path, "5555\n"},
+		{path + ".1", "44444444444\n"},
+		{path + ".2", "3333\n"},
+		{path + ".3", "<missing>"},
+	} {
+		if got := fileContent(t, tt.path); got != tt.want {
+			t.Errorf("%s = %q, want %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+func TestRotatingFileNoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	r, err := openRotatingFile(path, rotateConfig{MaxSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if err := r.setHeader([]byte("h\n")); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, "1111\n", "2222\n")
+
+	if got, want := fileContent(t, path), "h\n2222\n"; got != want {
+		t.Errorf("events.log = %q, want %q", got, want)
+	}
+	if got := fileContent(t, path+".1"); got != "<missing>" {
+		t.Errorf("events.log.1 = %q, want it missing", got)
+	}
+}
+
+// The size of the file appended to counts towards the max size.
+func TestRotatingFileAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	if err := os.WriteFile(path, []byte("0000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := openRotatingFile(path, rotateConfig{MaxSize: 8, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writeLines(t, r, "1111\n")
+
+	if got, want := fileContent(t, path+".1"), "0000\n"; got != want {
+		t.Errorf("events.log.1 = %q, want %q", got, want)
+	}
+	if got, want := fileContent(t, path), "1111\n"; got != want {
+		t.Errorf("events.log = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+// matchConfig matches the events. Empty lists match all, and the patterns
+// are globs of path.Match.
+type matchConfig struct {
+	Interfaces []string `yaml:"interfaces"`
+	// Drivers match the driver of the interface, e.g. mlx5_core.
+	Drivers []string `yaml:"drivers"`
+	// Masters match the master device of the interface, e.g. the bond or
+	// the bridge it is enslaved to. The denied commands have no master, as
+	// they are rejected before the interface is looked up.
+	Masters []string `yaml:"masters"`
+	// Commands match the names of the commands, e.g. ETHTOOL_SCHANNELS or
+	// ETHTOOL_MSG_CHANNELS_SET.
+	Commands []string `yaml:"commands"`
+	// Classes are get, set or action.
+	Classes []string `yaml:"classes"`
+	// Processes match the comm and the process name, e.g.
+	// "ethtool(parent 1:agent)".
+	Processes  []string `yaml:"processes"`
+	Containers []string `yaml:"containers"`
+	// Users match the user and the login user.
+	Users []string `yaml:"users"`
+	// Result is success, failure, denied or any, defaults to any. Denied
+	// commands are failures as well.
+	Result string `yaml:"result"`
+}
+
+// ruleConfig fires the actions on the events matching Match but not Except.
+type ruleConfig struct {
+	Name    string         `yaml:"name"`
+	Match   matchConfig    `yaml:"match"`
+	Except  *matchConfig   `yaml:"except"`
+	Actions []actionConfig `yaml:"actions"`
+}
+
+type matcher struct {
+	matchConfig
+	classes []ethtool.CmdClass
+}
+
+func newMatcher(cfg matchConfig) (*matcher, error) {
+	m := &matcher{matchConfig: cfg}
+
+	for _, c := range cfg.Classes {
+		class, err := ethtool.ParseCmdClass(c)
+		if err != nil {
+			return nil, err
+		}
+		m.classes = append(m.classes, class)
+	}
+
+	switch cfg.Result {
+	case "", "any", "success", "failure", "denied":
+	default:
+		return nil, fmt.Errorf("invalid result %q, expect success, failure, denied or any", cfg.Result)
+	}
+
+	for _, patterns := range [][]string{cfg.Interfaces, cfg.Drivers, cfg.Masters, cfg.Commands, cfg.Processes, cfg.Containers, cfg.Users} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func globMatch(patterns []string, names ...string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, p := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *matcher) match(ev *tracer.Event) bool {
+	switch m.Result {
+	case "success":
+		if ev.Failed() {
+			return false
+		}
+	case "failure":
+		if !ev.Failed() {
+			return false
+		}
+	case "denied":
+		if ev.Verdict != tracer.VerdictDenied {
+			return false
+		}
+	}
+
+	if len(m.classes) != 0 {
+		class, found := ev.Class(), false
+		for _, c := range m.classes {
+			found = found || c == class
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !globMatch(m.Interfaces, ev.Ifname) || !globMatch(m.Drivers, ev.Driver) || !globMatch(m.Commands, ev.Cmd()) ||
+		!globMatch(m.Processes, ev.Comm, ev.Process) || !globMatch(m.Containers, ev.Container) ||
+		!globMatch(m.Users, ev.User, ev.LoginUser) {
+		return false
+	}
+
+	return len(m.Masters) == 0 || globMatch(m.Masters, ev.Master)
+}
+
+type rule struct {
+	name    string
+	match   *matcher
+	except  *matcher
+	actions []action
+}
+
+func (r *rule) matches(ev *tracer.Event) bool {
+	return r.match.match(ev) && (r.except == nil || !r.except.match(ev))
+}
+
+// rules fire the actions of the matching rules on the events.
+type rules []*rule
+
+func newRules(cfgs []ruleConfig) (rules, error) {
+	var rs rules
+	for i, cfg := range cfgs {
+		if cfg.Name == "" {
+			cfg.Name = fmt.Sprintf("rule%d", i)
+		}
+
+		r, err := newRule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", cfg.Name, err)
+		}
+		rs = append(rs, r)
+	}
+
+	return rs, nil
+}
+
+func newRule(cfg ruleConfig) (*rule, error) {
+	r := &rule{name: cfg.Name}
+
+	var err error
+	if r.match, err = newMatcher(cfg.Match); err != nil {
+		return nil, fmt.Errorf("invalid match: %w", err)
+	}
+	if cfg.Except != nil {
+		if r.except, err = newMatcher(*cfg.Except); err != nil {
+			return nil, fmt.Errorf("invalid except: %w", err)
+		}
+	}
+
+	if len(cfg.Actions) == 0 {
+		return nil, errors.New("no actions")
+	}
+	for _, acfg := range cfg.Actions {
+		a, err := newAction(acfg)
+		if err != nil {
+			return nil, err
+		}
+		r.actions = append(r.actions, a)
+	}
+
+	return r, nil
+}
+
+// fire runs the actions of the matching rules asynchronously.
+func (rs rules) fire(ev *tracer.Event) {
+	for _, r := range rs {
+		if !r.matches(ev) {
+			continue
+		}
+
+		for _, a := range r.actions {
+			runAction(a, r.name, ev)
+		}
+	}
+}
+
+// loadRules loads the rules file, which is a YAML list of rules in the same
+// form as the rules of the daemon config.
+func loadRules(path string) (rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfgs []ruleConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfgs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return newRules(cfgs)
+}
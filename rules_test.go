@@ -0,0 +1,146 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+func TestMatcher(t *testing.T) {
+	// ethtool -L bond0's member eth0 combined 8, failed with EINVAL.
+	failed := &tracer.Event{
+		Type:    tracer.EventTypeGenl,
+		GenlCmd: ethtool.ETHTOOL_MSG_CHANNELS_SET,
+		Ifname:  "eth0",
+		Driver:  "mlx5_core",
+		Master:  "bond0",
+		Comm:    "ethtool",
+		Process: "ethtool(parent 1:agent)",
+		User:    "root",
+		Ret:     -22,
+	}
+	denied := &tracer.Event{
+		Type:     tracer.EventTypeIoctl,
+		IoctlCmd: ethtool.ETHTOOL_FLASHDEV,
+		Ifname:   "eth1",
+		Comm:     "fwupd",
+		Ret:      -1,
+		Verdict:  tracer.VerdictDenied,
+	}
+	get := &tracer.Event{
+		Type:     tracer.EventTypeIoctl,
+		IoctlCmd: ethtool.ETHTOOL_GDRVINFO,
+		Ifname:   "eth0",
+		Comm:     "ethtool",
+	}
+
+	for _, tt := range []struct {
+		name string
+		cfg  matchConfig
+		want []bool // whether failed, denied and get match
+	}{
+		{"all", matchConfig{}, []bool{true, true, true}},
+		{"interface", matchConfig{Interfaces: []string{"eth0"}}, []bool{true, false, true}},
+		{"driver", matchConfig{Drivers: []string{"mlx5_*"}}, []bool{true, false, false}},
+		{"master", matchConfig{Masters: []string{"bond*"}}, []bool{true, false, false}},
+		{"command", matchConfig{Commands: []string{"ETHTOOL_MSG_*_SET", "ETHTOOL_SCHANNELS"}}, []bool{true, false, false}},
+		{"classes", matchConfig{Classes: []string{"set", "action"}}, []bool{true, true, false}},
+		{"process name", matchConfig{Processes: []string{"*agent)"}}, []bool{true, false, false}},
+		{"comm", matchConfig{Processes: []string{"fwupd"}}, []bool{false, true, false}},
+		{"user", matchConfig{Users: []string{"root"}}, []bool{true, false, false}},
+		{"success", matchConfig{Result: "success"}, []bool{false, false, true}},
+		{"failure", matchConfig{Result: "failure"}, []bool{true, true, false}},
+		{"denied", matchConfig{Result: "denied"}, []bool{false, true, false}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newMatcher(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, ev := range []*tracer.Event{failed, denied, get} {
+				if got := m.match(ev); got != tt.want[i] {
+					t.Errorf("match(%s) = %v, want %v", ev.Cmd(), got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatcherInvalid(t *testing.T) {
+	for _, cfg := range []matchConfig{
+		{Classes: []string{"unknown"}},
+		{Result: "ok"},
+		{Interfaces: []string{"eth["}},
+	} {
+		if _, err := newMatcher(cfg); err == nil {
+			t.Errorf("newMatcher(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestRuleExcept(t *testing.T) {
+	r, err := newRule(ruleConfig{
+		Name:    "channels",
+		Match:   matchConfig{Commands: []string{"ETHTOOL_MSG_CHANNELS_SET"}},
+		Except:  &matchConfig{Processes: []string{"*agent)"}},
+		Actions: []actionConfig{{FIFO: "/dev/null"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := &tracer.Event{Type: tracer.EventTypeGenl, GenlCmd: ethtool.ETHTOOL_MSG_CHANNELS_SET, Comm: "ethtool"}
+	if !r.matches(ev) {
+		t.Errorf("the rule does not match %s of ethtool", ev.Cmd())
+	}
+
+	ev.Process = "ethtool(parent 1:agent)"
+	if r.matches(ev) {
+		t.Errorf("the rule matches %s of the excepted agent", ev.Cmd())
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tt := range []struct {
+		name    string
+		content string
+		rules   int
+		invalid bool
+	}{
+		{"empty", "", 0, false},
+		{"named", "- name: flash\n  match:\n    commands: [ETHTOOL_FLASHDEV]\n  actions:\n    - fifo: /dev/null\n", 1, false},
+		{"unknown field", "- name: flash\n  match:\n    command: [ETHTOOL_FLASHDEV]\n  actions:\n    - fifo: /dev/null\n", 0, true},
+		{"no actions", "- name: flash\n", 0, true},
+		{"two actions in one", "- actions:\n    - fifo: /dev/null\n      audit: true\n", 0, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			rs, err := loadRules(path)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("loadRules() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rs) != tt.rules {
+				t.Errorf("loadRules() = %d rules, want %d", len(rs), tt.rules)
+			}
+		})
+	}
+}
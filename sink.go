@@ -0,0 +1,215 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+// sink outputs the traced events.
+type sink interface {
+	write(ev *tracer.Event) error
+	io.Closer
+}
+
+type field struct {
+	key   string
+	value any
+}
+
+// eventFields returns the structured fields of the event, which are shared by
+// all the sinks except the table.
+func eventFields(ev *tracer.Event) []field {
+	return []field{
+		{"time", ev.Time.Format(time.RFC3339Nano)},
+		{"type", ev.Type.String()},
+		{"ifname", ev.Ifname},
+		{"ifindex", ev.Ifindex},
+		{"dev_by", ev.DevBy},
+		{"altname", ev.Altname},
+		{"header_flags", strings.Join(ev.HeaderFlags, ",")},
+		{"driver", ev.Driver},
+		{"bus", ev.Bus},
+		{"master", ev.Master},
+		{"caller", ev.Caller},
+		{"pid", ev.Pid},
+		{"comm", ev.Comm},
+		{"process", ev.Process},
+		{"container", ev.Container},
+		{"cmd", ev.Cmd()},
+		{"args", ev.Message()},
+		{"details", ev.Details},
+		{"ret", ev.Ret},
+		{"duration", ev.Duration.String()},
+		{"verdict", ev.Verdict.String()},
+		{"uid", ev.Uid},
+		{"gid", ev.Gid},
+		{"user", ev.User},
+		{"loginuid", int64(int32(ev.LoginUid))},
+		{"login_user", ev.LoginUser},
+		{"sessionid", int64(int32(ev.SessionID))},
+		{"tty", ev.TTY},
+		{"id", ev.ID},
+		{"parent", ev.Parent},
+		{"op", ev.Op},
+		{"func", ev.Func},
+		{"kernel_stack", strings.Join(ev.KernelStack, ";")},
+		{"user_stack", strings.Join(ev.UserStack, ";")},
+	}
+}
+
+// eventUser returns who issued the command, e.g. alice@pts/3, preferring the
+// login user which survives su and sudo.
+func eventUser(ev *tracer.Event) string {
+	who := ev.LoginUser
+	if who == "" {
+		who = ev.User
+	}
+	if who == "" {
+		who = strconv.FormatUint(uint64(ev.Uid), 10)
+	}
+	if ev.TTY != "" {
+		who += "@" + ev.TTY
+	}
+
+	return who
+}
+
+// nopCloser wraps stdout, which must not be closed by the sinks.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// outputConfig configures a sink.
+type outputConfig struct {
+	Format string        `yaml:"format"`
+	Path   string        `yaml:"path"`
+	Rotate *rotateConfig `yaml:"rotate"`
+}
+
+func openOutput(cfg outputConfig) (io.WriteCloser, error) {
+	if cfg.Path == "" || cfg.Path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	if cfg.Rotate != nil && cfg.Rotate.MaxSize > 0 {
+		return openRotatingFile(cfg.Path, *cfg.Rotate)
+	}
+
+	return os.OpenFile(cfg.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+}
+
+// parseOutput parses the output spec, which is <format>[:<path>]. The output
+// is stdout if the path is omitted.
+func parseOutput(spec string) outputConfig {
+	format, path, _ := strings.Cut(spec, ":")
+	return outputConfig{Format: format, Path: path}
+}
+
+// newSink creates the sink by the output config.
+func newSink(cfg outputConfig) (sink, error) {
+	switch cfg.Format {
+	case "syslog":
+		return newSyslogSink()
+	case "journald":
+		return newJournaldSink()
+	}
+
+	var newFn func(io.WriteCloser) (sink, error)
+	switch cfg.Format {
+	case "table":
+		newFn = newTableSink
+	case "json":
+		newFn = newJSONSink
+	case "logfmt":
+		newFn = newLogfmtSink
+	case "csv":
+		newFn = newCSVSink
+	default:
+		return nil, fmt.Errorf("unknown output format %q", cfg.Format)
+	}
+
+	w, err := openOutput(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output %s: %w", cfg.Path, err)
+	}
+
+	s, err := newFn(w)
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("failed to write output %s: %w", cfg.Path, err)
+	}
+
+	return s, nil
+}
+
+func parseOutputs(specs []string) []outputConfig {
+	cfgs := make([]outputConfig, 0, len(specs))
+	for _, spec := range specs {
+		cfgs = append(cfgs, parseOutput(spec))
+	}
+
+	return cfgs
+}
+
+// multiSink writes the events to all the sinks. The ethtool_ops callbacks of
+// the events are written as separate events following their parents, except
+// to the table, which nests them under the parents.
+type multiSink []sink
+
+func newMultiSink(cfgs []outputConfig) (multiSink, error) {
+	var sinks multiSink
+	for _, cfg := range cfgs {
+		s, err := newSink(cfg)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+
+		sinks = append(sinks, s)
+	}
+
+	return sinks, nil
+}
+
+func (m multiSink) write(ev *tracer.Event) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.write(ev); err != nil {
+			errs = append(errs, err)
+		}
+
+		if _, nested := s.(*tableSink); nested {
+			continue
+		}
+		for _, op := range ev.Ops {
+			if err := s.write(op); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m multiSink) Close() error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"strings"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+const (
+	syslogTag = "ethtoolsnoop"
+
+	journaldSocket = "/run/systemd/journal/socket"
+)
+
+func eventSummary(ev *tracer.Event) string {
+	return fmt.Sprintf("%s on %s by %s via %d:%s", ev.Cmd(), ev.Ifname, eventUser(ev), ev.Pid, ev.Process)
+}
+
+// syslogSink sends the events to the local syslog daemon as logfmt messages.
+type syslogSink struct {
+	w   *syslog.Writer
+	buf bytes.Buffer
+}
+
+func newSyslogSink() (sink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) write(ev *tracer.Event) error {
+	s.buf.Reset()
+	appendLogfmt(&s.buf, eventFields(ev))
+
+	return s.w.Info(s.buf.String())
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+// journaldSink sends the events to systemd-journald by its native protocol,
+// with the event fields as ETHTOOL_* structured fields.
+type journaldSink struct {
+	conn *net.UnixConn
+	buf  bytes.Buffer
+}
+
+func newJournaldSink() (sink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+
+	return &journaldSink{conn: conn}, nil
+}
+
+func (s *journaldSink) writeField(key, value string) {
+	s.buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		s.buf.WriteByte('=')
+		s.buf.WriteString(value)
+		s.buf.WriteByte('\n')
+		return
+	}
+
+	// Multi-line values are serialized as the key, a newline, the 64-bit
+	// little-endian size, the value and a newline.
+	s.buf.WriteByte('\n')
+	_ = binary.Write(&s.buf, binary.LittleEndian, uint64(len(value)))
+	s.buf.WriteString(value)
+	s.buf.WriteByte('\n')
+}
+
+func (s *journaldSink) write(ev *tracer.Event) error {
+	s.buf.Reset()
+	s.writeField("MESSAGE", eventSummary(ev))
+	s.writeField("PRIORITY", "6")
+	s.writeField("SYSLOG_IDENTIFIER", syslogTag)
+	for _, f := range eventFields(ev) {
+		s.writeField("ETHTOOL_"+strings.ToUpper(f.key), fmt.Sprint(f.value))
+	}
+
+	_, err := s.conn.Write(s.buf.Bytes())
+	return err
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
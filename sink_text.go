@@ -0,0 +1,272 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+// tableSink prints the events as a human-readable table.
+// With --show-driver, it prints the driver and the bus address of the
+// interface as well. The ethtool_ops callbacks and the stacks are printed
+// indented under the events.
+type tableSink struct {
+	w io.WriteCloser
+}
+
+func tableInterface(ifname, driver, bus string) string {
+	if flags.showDriver {
+		return fmt.Sprintf("%-16s %-12s %-14s", ifname, driver, bus)
+	}
+
+	return fmt.Sprintf("%-16s", ifname)
+}
+
+func newTableSink(w io.WriteCloser) (sink, error) {
+	_, err := fmt.Fprintf(w, "%s %-20s %8s:%-32s %-30s %s\n", tableInterface("Interface", "Driver", "Bus"), "User", "PID", "Process", "IOCTL_CMD/GENL_CMD", "ethtool args")
+	return &tableSink{w: w}, err
+}
+
+// opResult returns the result of the ethtool_ops callback, e.g.
+// "mlx5e_set_ringparam 12.3µs => EINVAL".
+func opResult(op *tracer.Event) string {
+	res := fmt.Sprintf("%s %s", op.Func, op.Duration)
+	if op.Failed() {
+		res += fmt.Sprintf(" => %s", op.Errno())
+	}
+
+	return res
+}
+
+func (s *tableSink) write(ev *tracer.Event) error {
+	if ev.Type == tracer.EventTypeOp {
+		if _, err := fmt.Fprintf(s.w, "%s %-20s %8d:%-32s %-30s %s\n", tableInterface(ev.Ifname, ev.Driver, ev.Bus), eventUser(ev), ev.Pid, ev.Process, "op:"+ev.Op, opResult(ev)); err != nil {
+			return err
+		}
+
+		return s.writeStacks(ev)
+	}
+
+	msg := ev.Message()
+	if flags.debug {
+		msg = "from " + ev.Type.String()
+	}
+	if ev.Type == tracer.EventTypeKernel {
+		msg = "in-kernel from " + ev.Caller
+	}
+	if ev.Type == tracer.EventTypeNotify {
+		msg = "notification"
+		if ev.Parent != 0 {
+			msg += " caused by the command of the process"
+		}
+	}
+	if ev.Details != "" {
+		msg += ": " + ev.Details
+	}
+	if ev.Verdict == tracer.VerdictDenied {
+		msg += " => denied"
+	} else if ev.Failed() {
+		msg += fmt.Sprintf(" => %s", ev.Errno())
+	}
+
+	// The altname given by the user is shown next to the name of the
+	// netdev.
+	ifname := ev.Ifname
+	if ev.Altname != "" {
+		ifname += " (" + ev.Altname + ")"
+	}
+
+	if _, err := fmt.Fprintf(s.w, "%s %-20s %8d:%-32s %-30s %s\n", tableInterface(ifname, ev.Driver, ev.Bus), eventUser(ev), ev.Pid, ev.Process, ev.Cmd(), msg); err != nil {
+		return err
+	}
+
+	for _, op := range ev.Ops {
+		if _, err := fmt.Fprintf(s.w, "%s %-20s %8s %-32s   -> %-25s %s\n", tableInterface("", "", ""), "", "", "", op.Op, opResult(op)); err != nil {
+			return err
+		}
+	}
+
+	return s.writeStacks(ev)
+}
+
+// writeStacks prints the frames of the stacks, the kernel ones first.
+func (s *tableSink) writeStacks(ev *tracer.Event) error {
+	for _, stack := range []struct {
+		tag    string
+		frames []string
+	}{
+		{"[k]", ev.KernelStack},
+		{"[u]", ev.UserStack},
+	} {
+		for _, frame := range stack.frames {
+			if _, err := fmt.Fprintf(s.w, "    %s %s\n", stack.tag, frame); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *tableSink) Close() error {
+	return s.w.Close()
+}
+
+// jsonSink prints the events as JSON lines.
+type jsonSink struct {
+	w   io.WriteCloser
+	buf bytes.Buffer
+}
+
+func newJSONSink(w io.WriteCloser) (sink, error) {
+	return &jsonSink{w: w}, nil
+}
+
+// appendJSON appends the fields as a JSON object, keeping their order.
+func appendJSON(buf *bytes.Buffer, fields []field) error {
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(f.key)
+		val, err := json.Marshal(f.value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", f.key, err)
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+
+	return nil
+}
+
+func (s *jsonSink) write(ev *tracer.Event) error {
+	s.buf.Reset()
+	if err := appendJSON(&s.buf, eventFields(ev)); err != nil {
+		return err
+	}
+	s.buf.WriteByte('\n')
+
+	_, err := s.w.Write(s.buf.Bytes())
+	return err
+}
+
+func (s *jsonSink) Close() error {
+	return s.w.Close()
+}
+
+// logfmtSink prints the events as logfmt lines.
+type logfmtSink struct {
+	w   io.WriteCloser
+	buf bytes.Buffer
+}
+
+func newLogfmtSink(w io.WriteCloser) (sink, error) {
+	return &logfmtSink{w: w}, nil
+}
+
+func logfmtValue(v any) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+func appendLogfmt(buf *bytes.Buffer, fields []field) {
+	for i, f := range fields {
+		if i != 0 {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(f.key)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(f.value))
+	}
+}
+
+func (s *logfmtSink) write(ev *tracer.Event) error {
+	s.buf.Reset()
+	appendLogfmt(&s.buf, eventFields(ev))
+	s.buf.WriteByte('\n')
+
+	_, err := s.w.Write(s.buf.Bytes())
+	return err
+}
+
+func (s *logfmtSink) Close() error {
+	return s.w.Close()
+}
+
+// csvSink prints the events as CSV records, with a header record first. The
+// header is written only at the top of a file, which is appended to.
+type csvSink struct {
+	w  io.WriteCloser
+	cw *csv.Writer
+}
+
+func newCSVSink(w io.WriteCloser) (sink, error) {
+	s := &csvSink{w: w, cw: csv.NewWriter(w)}
+
+	fields := eventFields(&tracer.Event{})
+	keys := make([]string, 0, len(fields))
+	for _, f := range fields {
+		keys = append(keys, f.key)
+	}
+
+	var header bytes.Buffer
+	hw := csv.NewWriter(&header)
+	if err := hw.Write(keys); err != nil {
+		return nil, err
+	}
+	hw.Flush()
+
+	switch f := w.(type) {
+	case *rotatingFile:
+		// Every rotated file starts with the header as well.
+		return s, f.setHeader(header.Bytes())
+	case *os.File:
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() != 0 {
+			return s, nil
+		}
+	}
+
+	_, err := w.Write(header.Bytes())
+	return s, err
+}
+
+func (s *csvSink) write(ev *tracer.Event) error {
+	fields := eventFields(ev)
+
+	values := make([]string, 0, len(fields))
+	for _, f := range fields {
+		values = append(values, fmt.Sprint(f.value))
+	}
+	if err := s.cw.Write(values); err != nil {
+		return err
+	}
+
+	s.cw.Flush()
+	return s.cw.Error()
+}
+
+func (s *csvSink) Close() error {
+	s.cw.Flush()
+	return s.w.Close()
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 Leon Hwang.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Asphaltt/ethtoolsnoop/pkg/ethtool"
+	"github.com/Asphaltt/ethtoolsnoop/pkg/tracer"
+)
+
+func testEvent() *tracer.Event {
+	return &tracer.Event{
+		Type:     tracer.EventTypeIoctl,
+		IoctlCmd: ethtool.ETHTOOL_SCHANNELS,
+		Ifname:   "eth0",
+		Pid:      42,
+		Comm:     "ethtool",
+		Process:  "ethtool",
+		Details:  "combined 8",
+	}
+}
+
+// readCSV reads the records of the CSV file.
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse %s: %s", path, err)
+	}
+
+	return records
+}
+
+// The header is written only at the top of the file, which is appended to by
+// the later runs.
+func TestCSVSinkHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.csv")
+
+	for i := 0; i < 2; i++ {
+		s, err := newSink(outputConfig{Format: "csv", Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.write(testEvent()); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readCSV(t, path)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want the header and 2 events", len(records))
+	}
+	if records[0][0] != "time" || records[1][0] == "time" || records[2][0] == "time" {
+		t.Errorf("header is not only at the top: %q", records)
+	}
+}
+
+// Every rotated file starts with the header.
+func TestCSVSinkRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.csv")
+
+	s, err := newSink(outputConfig{Format: "csv", Path: path, Rotate: &rotateConfig{MaxSize: 1, MaxBackups: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.write(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{path, path + ".1"} {
+		records := readCSV(t, p)
+		if len(records) == 0 || records[0][0] != "time" {
+			t.Errorf("%s does not start with the header: %q", filepath.Base(p), records)
+		}
+	}
+}
+
+func TestLogfmtValue(t *testing.T) {
+	for _, tt := range []struct {
+		value any
+		want  string
+	}{
+		{"eth0", "eth0"},
+		{42, "42"},
+		{"", `""`},
+		{"combined 8", `"combined 8"`},
+		{`a="b"`, `"a=\"b\""`},
+	} {
+		if got := logfmtValue(tt.value); got != tt.want {
+			t.Errorf("logfmtValue(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAppendJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := appendJSON(&buf, []field{{"ifname", "eth0"}, {"pid", 42}, {"args", "a\"b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"ifname":"eth0","pid":42,"args":"a\"b"}`; buf.String() != want {
+		t.Errorf("appendJSON() = %s, want %s", buf.String(), want)
+	}
+}
+
+type closeBuffer struct {
+	bytes.Buffer
+}
+
+func (*closeBuffer) Close() error { return nil }
+
+func TestTableSink(t *testing.T) {
+	var buf closeBuffer
+	s, err := newTableSink(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := testEvent()
+	ev.Ret = -22
+	if err := s.write(ev); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want the header and 1 event", len(lines))
+	}
+	if !strings.HasSuffix(lines[1], ": combined 8 => "+ev.Errno().Error()) {
+		t.Errorf("event line %q misses the details and the errno", lines[1])
+	}
+}
+
+// The details of the notifications are kept, with --debug as well.
+func TestTableSinkNotify(t *testing.T) {
+	saved := flags
+	t.Cleanup(func() { flags = saved })
+
+	ev := &tracer.Event{
+		Type:      tracer.EventTypeNotify,
+		NotifyCmd: ethtool.ETHTOOL_MSG_MODULE_FW_FLASH_NTF,
+		Ifname:    "eth0",
+		Details:   "in progress 1024/65536",
+		Parent:    1,
+	}
+
+	const want = "notification caused by the command of the process: in progress 1024/65536"
+	for _, debug := range []bool{false, true} {
+		flags.debug = debug
+
+		var buf closeBuffer
+		s, err := newTableSink(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.write(ev); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if !strings.HasSuffix(lines[len(lines)-1], want) {
+			t.Errorf("debug %v: event line %q, want the suffix %q", debug, lines[len(lines)-1], want)
+		}
+	}
+}